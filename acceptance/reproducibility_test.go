@@ -21,20 +21,20 @@ import (
 	h "github.com/buildpacks/imgutil/testhelpers"
 )
 
-var registryPort string
+var registryHost string
 
 func newTestImageName() string {
-	return "localhost:" + registryPort + "/imgutil-acceptance-" + h.RandString(10)
+	return registryHost + "/imgutil-acceptance-" + h.RandString(10)
 }
 
 func TestAcceptance(t *testing.T) {
 	rand.Seed(time.Now().UTC().UnixNano())
 
-	dockerRegistry := h.NewDockerRegistry()
-	dockerRegistry.Start(t)
-	defer dockerRegistry.Stop(t)
+	registry := h.NewTestRegistry()
+	registry.Start(t)
+	defer registry.Stop(t)
 
-	registryPort = dockerRegistry.Port
+	registryHost = registry.Host()
 
 	spec.Run(t, "Reproducibility", testReproducibility, spec.Sequential(), spec.Report(report.Terminal{}))
 }
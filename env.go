@@ -0,0 +1,86 @@
+package imgutil
+
+import (
+	"strings"
+)
+
+// The functions below operate on environment variables in the KEY=value form stored in image configs.
+// Keys are matched case-insensitively when os is "windows", mirroring how Windows treats environment variables.
+
+// GetEnv returns the value of key in env. When key occurs more than once the last entry wins, as it does at runtime.
+func GetEnv(env []string, os, key string) (string, bool) {
+	for idx := len(env) - 1; idx >= 0; idx-- {
+		k, v := splitEnv(env[idx])
+		if envKeysEqual(os, k, key) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// EnvMap returns the variables in env keyed by name.
+func EnvMap(env []string, os string) map[string]string {
+	envMap := make(map[string]string, len(env))
+	for _, e := range env {
+		k, v := splitEnv(e)
+		if os == "windows" {
+			for existing := range envMap {
+				if strings.EqualFold(existing, k) {
+					delete(envMap, existing)
+				}
+			}
+		}
+		envMap[k] = v
+	}
+	return envMap
+}
+
+// SetEnv returns a copy of env with key set to val.
+// An existing entry for key is replaced in place and any further entries for key are dropped; otherwise the entry is appended.
+func SetEnv(env []string, os, key, val string) []string {
+	entry := key + "=" + val
+	result := make([]string, 0, len(env)+1)
+	found := false
+	for _, e := range env {
+		k, _ := splitEnv(e)
+		if !envKeysEqual(os, k, key) {
+			result = append(result, e)
+			continue
+		}
+		if !found {
+			result = append(result, entry)
+			found = true
+		}
+	}
+	if !found {
+		result = append(result, entry)
+	}
+	return result
+}
+
+// RemoveEnv returns a copy of env without any entries for key.
+func RemoveEnv(env []string, os, key string) []string {
+	result := make([]string, 0, len(env))
+	for _, e := range env {
+		k, _ := splitEnv(e)
+		if !envKeysEqual(os, k, key) {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+func splitEnv(e string) (string, string) {
+	parts := strings.SplitN(e, "=", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+func envKeysEqual(os, a, b string) bool {
+	if os == "windows" {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
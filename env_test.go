@@ -0,0 +1,177 @@
+package imgutil_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/fakes"
+	"github.com/buildpacks/imgutil/layout"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestEnv(t *testing.T) {
+	spec.Run(t, "Env", testEnv, spec.Parallel(), spec.Report(report.Terminal{}))
+	spec.Run(t, "ImageEnv", testImageEnv, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testEnv(t *testing.T, when spec.G, it spec.S) {
+	when("#GetEnv", func() {
+		it("preserves '=' in values", func() {
+			val, ok := imgutil.GetEnv([]string{"KEY=some=value"}, "linux", "KEY")
+			h.AssertEq(t, ok, true)
+			h.AssertEq(t, val, "some=value")
+		})
+
+		it("returns the last entry for a duplicated key", func() {
+			val, _ := imgutil.GetEnv([]string{"KEY=first", "KEY=second"}, "linux", "KEY")
+			h.AssertEq(t, val, "second")
+		})
+
+		it("reports a missing key", func() {
+			_, ok := imgutil.GetEnv([]string{"KEY=value"}, "linux", "OTHER")
+			h.AssertEq(t, ok, false)
+		})
+
+		it("matches keys case-insensitively on windows only", func() {
+			_, ok := imgutil.GetEnv([]string{"Path=value"}, "linux", "PATH")
+			h.AssertEq(t, ok, false)
+
+			val, ok := imgutil.GetEnv([]string{"Path=value"}, "windows", "PATH")
+			h.AssertEq(t, ok, true)
+			h.AssertEq(t, val, "value")
+		})
+	})
+
+	when("#SetEnv", func() {
+		it("replaces an existing entry in place", func() {
+			env := imgutil.SetEnv([]string{"A=1", "KEY=old", "B=2"}, "linux", "KEY", "new")
+			h.AssertEq(t, env, []string{"A=1", "KEY=new", "B=2"})
+		})
+
+		it("drops duplicate entries", func() {
+			env := imgutil.SetEnv([]string{"KEY=1", "A=1", "KEY=2"}, "linux", "KEY", "3")
+			h.AssertEq(t, env, []string{"KEY=3", "A=1"})
+		})
+
+		it("appends a new entry", func() {
+			env := imgutil.SetEnv([]string{"A=1"}, "linux", "KEY", "a=b")
+			h.AssertEq(t, env, []string{"A=1", "KEY=a=b"})
+		})
+
+		it("does not modify the given slice", func() {
+			original := []string{"KEY=old"}
+			imgutil.SetEnv(original, "linux", "KEY", "new")
+			h.AssertEq(t, original, []string{"KEY=old"})
+		})
+
+		it("replaces keys case-insensitively on windows", func() {
+			env := imgutil.SetEnv([]string{"Path=old"}, "windows", "PATH", "new")
+			h.AssertEq(t, env, []string{"PATH=new"})
+
+			env = imgutil.SetEnv([]string{"Path=old"}, "linux", "PATH", "new")
+			h.AssertEq(t, env, []string{"Path=old", "PATH=new"})
+		})
+	})
+
+	when("#RemoveEnv", func() {
+		it("removes all entries for the key", func() {
+			env := imgutil.RemoveEnv([]string{"KEY=1", "A=1", "KEY=2"}, "linux", "KEY")
+			h.AssertEq(t, env, []string{"A=1"})
+		})
+
+		it("removes keys case-insensitively on windows", func() {
+			env := imgutil.RemoveEnv([]string{"Path=1", "A=1"}, "windows", "PATH")
+			h.AssertEq(t, env, []string{"A=1"})
+		})
+	})
+
+	when("#EnvMap", func() {
+		it("keys values by name", func() {
+			envMap := imgutil.EnvMap([]string{"A=1", "B=x=y", "A=2"}, "linux")
+			h.AssertEq(t, envMap, map[string]string{"A": "2", "B": "x=y"})
+		})
+
+		it("merges keys that differ only by case on windows", func() {
+			envMap := imgutil.EnvMap([]string{"Path=1", "PATH=2"}, "windows")
+			h.AssertEq(t, envMap, map[string]string{"PATH": "2"})
+		})
+	})
+}
+
+func testImageEnv(t *testing.T, when spec.G, it spec.S) {
+	var tmpDir string
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "imgutil.env.test.")
+		h.AssertNil(t, err)
+	})
+
+	it.After(func() {
+		h.AssertNil(t, os.RemoveAll(tmpDir))
+	})
+
+	backends := map[string]func() imgutil.Image{
+		"layout": func() imgutil.Image {
+			img, err := layout.NewImage(filepath.Join(tmpDir, "image"))
+			h.AssertNil(t, err)
+			return img
+		},
+		"fakes": func() imgutil.Image {
+			return fakes.NewImage("some-image", "", nil)
+		},
+	}
+
+	for name, newImage := range backends {
+		newImage := newImage
+
+		when(name, func() {
+			it("replaces values and preserves '='", func() {
+				img := newImage()
+
+				h.AssertNil(t, img.SetEnv("KEY", "old"))
+				h.AssertNil(t, img.SetEnv("OTHER", "other"))
+				h.AssertNil(t, img.SetEnv("KEY", "new=value"))
+
+				val, err := img.Env("KEY")
+				h.AssertNil(t, err)
+				h.AssertEq(t, val, "new=value")
+
+				envVars, err := img.EnvVars()
+				h.AssertNil(t, err)
+				h.AssertEq(t, envVars, map[string]string{"KEY": "new=value", "OTHER": "other"})
+			})
+
+			it("removes values", func() {
+				img := newImage()
+
+				h.AssertNil(t, img.SetEnv("KEY", "value"))
+				h.AssertNil(t, img.RemoveEnv("KEY"))
+
+				val, err := img.Env("KEY")
+				h.AssertNil(t, err)
+				h.AssertEq(t, val, "")
+
+				envVars, err := img.EnvVars()
+				h.AssertNil(t, err)
+				h.AssertEq(t, len(envVars), 0)
+			})
+
+			it("treats keys as case-sensitive on linux", func() {
+				img := newImage()
+
+				h.AssertNil(t, img.SetEnv("Key", "value"))
+
+				val, err := img.Env("KEY")
+				h.AssertNil(t, err)
+				h.AssertEq(t, val, "")
+			})
+		})
+	}
+}
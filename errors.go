@@ -0,0 +1,62 @@
+package imgutil
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors describing why an operation on an image failed. Errors returned by the image backends can be
+// matched against them with errors.Is, and unwrapped to an *Error with errors.As.
+var (
+	// ErrImageNotFound is returned when an image doesn't exist in its repository.
+	ErrImageNotFound = errors.New("image not found")
+	// ErrLayerNotFound is returned when an image doesn't have a layer with a given diff id.
+	ErrLayerNotFound = errors.New("layer not found")
+	// ErrUnauthorized is returned when the registry or daemon refused access to an image.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrPlatformMismatch is returned when no image matches the requested or expected platform.
+	ErrPlatformMismatch = errors.New("platform mismatch")
+	// ErrLabelMismatch is returned when a label of an image doesn't have its expected value.
+	ErrLabelMismatch = errors.New("label mismatch")
+	// ErrInvalidImage is returned when an image's manifest, config or layers are missing or inconsistent.
+	ErrInvalidImage = errors.New("invalid image")
+	// ErrBaseLayer is returned when an operation would change a layer that belongs to the base image of an image.
+	ErrBaseLayer = errors.New("base layer")
+)
+
+// Error is an error about an image. Kind is one of the sentinel errors above, and Err is the underlying cause, if
+// any.
+type Error struct {
+	Kind    error
+	Message string
+	Err     error
+}
+
+// Errorf returns an *Error of the given kind whose message is formatted from format and args.
+func Errorf(kind error, format string, args ...interface{}) error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, args...)}
+}
+
+// Wrapf returns an *Error of the given kind caused by err, or nil if err is nil.
+func Wrapf(kind, err error, format string, args ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, args...), Err: err}
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+	return e.Message + ": " + e.Err.Error()
+}
+
+// Is reports whether target is the kind of e.
+func (e *Error) Is(target error) bool {
+	return e.Kind != nil && e.Kind == target
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,55 @@
+package imgutil_test
+
+import (
+	"errors"
+	"testing"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestErrors(t *testing.T) {
+	spec.Run(t, "Errors", testErrors, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testErrors(t *testing.T, when spec.G, it spec.S) {
+	when("#Errorf", func() {
+		it("formats the message and matches its kind", func() {
+			err := imgutil.Errorf(imgutil.ErrLayerNotFound, "image '%s' has no layer with diff id '%s'", "some-image", "some-diff-id")
+
+			h.AssertEq(t, err.Error(), "image 'some-image' has no layer with diff id 'some-diff-id'")
+			h.AssertEq(t, errors.Is(err, imgutil.ErrLayerNotFound), true)
+			h.AssertEq(t, errors.Is(err, imgutil.ErrImageNotFound), false)
+		})
+
+		it("matches its kind through wrapping", func() {
+			err := pkgerrors.Wrap(imgutil.Errorf(imgutil.ErrPlatformMismatch, "some-message"), "some-context")
+
+			h.AssertEq(t, errors.Is(err, imgutil.ErrPlatformMismatch), true)
+
+			var imgErr *imgutil.Error
+			h.AssertEq(t, errors.As(err, &imgErr), true)
+			h.AssertEq(t, imgErr.Kind == imgutil.ErrPlatformMismatch, true)
+			h.AssertEq(t, imgErr.Message, "some-message")
+		})
+	})
+
+	when("#Wrapf", func() {
+		it("includes and unwraps to the cause", func() {
+			cause := errors.New("some-cause")
+			err := imgutil.Wrapf(imgutil.ErrUnauthorized, cause, "read image '%s'", "some-image")
+
+			h.AssertEq(t, err.Error(), "read image 'some-image': some-cause")
+			h.AssertEq(t, errors.Is(err, imgutil.ErrUnauthorized), true)
+			h.AssertEq(t, errors.Is(err, cause), true)
+		})
+
+		it("returns nil without a cause", func() {
+			h.AssertNil(t, imgutil.Wrapf(imgutil.ErrUnauthorized, nil, "some-message"))
+		})
+	})
+}
@@ -2,6 +2,7 @@ package fakes
 
 import (
 	"archive/tar"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
@@ -21,7 +22,8 @@ import (
 func NewImage(name, topLayerSha string, identifier imgutil.Identifier) *Image {
 	return &Image{
 		labels:        map[string]string{},
-		env:           map[string]string{},
+		exposedPorts:  map[string]struct{}{},
+		volumes:       map[string]struct{}{},
 		topLayerSha:   topLayerSha,
 		identifier:    identifier,
 		name:          name,
@@ -43,7 +45,7 @@ type Image struct {
 	prevLayersMap map[string]string
 	reusedLayers  []string
 	labels        map[string]string
-	env           map[string]string
+	env           []string
 	topLayerSha   string
 	os            string
 	osVersion     string
@@ -56,6 +58,13 @@ type Image struct {
 	createdAt     time.Time
 	layerDir      string
 	workingDir    string
+	user          string
+	exposedPorts  map[string]struct{}
+	volumes       map[string]struct{}
+	stopSignal    string
+	healthcheck   *imgutil.HealthConfig
+	shell         []string
+	onBuild       []string
 	savedNames    map[string]bool
 }
 
@@ -67,12 +76,48 @@ func (i *Image) Label(key string) (string, error) {
 	return i.labels[key], nil
 }
 
+func (i *Image) Labels() (map[string]string, error) {
+	labels := make(map[string]string, len(i.labels))
+	for k, v := range i.labels {
+		labels[k] = v
+	}
+	return labels, nil
+}
+
+func (i *Image) EnvVars() (map[string]string, error) {
+	return imgutil.EnvMap(i.env, i.os), nil
+}
+
+func (i *Image) User() (string, error) {
+	return i.user, nil
+}
+
+func (i *Image) ExposedPorts() (map[string]struct{}, error) {
+	return copySet(i.exposedPorts), nil
+}
+
+func (i *Image) Volumes() (map[string]struct{}, error) {
+	return copySet(i.volumes), nil
+}
+
+func (i *Image) StopSignal() (string, error) {
+	return i.stopSignal, nil
+}
+
+func copySet(set map[string]struct{}) map[string]struct{} {
+	c := make(map[string]struct{}, len(set))
+	for k := range set {
+		c[k] = struct{}{}
+	}
+	return c
+}
+
 func (i *Image) OS() (string, error) {
 	return i.os, nil
 }
 
 func (i *Image) OSVersion() (string, error) {
-	return i.os, nil
+	return i.osVersion, nil
 }
 
 func (i *Image) Architecture() (string, error) {
@@ -91,18 +136,28 @@ func (i *Image) Identifier() (imgutil.Identifier, error) {
 	return i.identifier, nil
 }
 
-func (i *Image) Rebase(baseTopLayer string, newBase imgutil.Image) error {
+func (i *Image) Rebase(baseTopLayer string, newBase imgutil.Image, ops ...imgutil.RebaseOption) error {
+	if err := imgutil.ValidateRebase(i, newBase, ops...); err != nil {
+		return err
+	}
 	i.base = newBase.Name()
 	return nil
 }
 
+func (i *Image) RebaseContext(ctx context.Context, baseTopLayer string, newBase imgutil.Image, ops ...imgutil.RebaseOption) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return i.Rebase(baseTopLayer, newBase, ops...)
+}
+
 func (i *Image) SetLabel(k string, v string) error {
 	i.labels[k] = v
 	return nil
 }
 
 func (i *Image) SetEnv(k string, v string) error {
-	i.env[k] = v
+	i.env = imgutil.SetEnv(i.env, i.os, k, v)
 	return nil
 }
 
@@ -121,14 +176,64 @@ func (i *Image) SetCmd(v ...string) error {
 	return nil
 }
 
+func (i *Image) SetUser(user string) error {
+	i.user = user
+	return nil
+}
+
+func (i *Image) SetExposedPorts(ports map[string]struct{}) error {
+	i.exposedPorts = copySet(ports)
+	return nil
+}
+
+func (i *Image) SetVolumes(volumes map[string]struct{}) error {
+	i.volumes = copySet(volumes)
+	return nil
+}
+
+func (i *Image) SetHealthcheck(healthcheck imgutil.HealthConfig) error {
+	i.healthcheck = &healthcheck
+	return nil
+}
+
+func (i *Image) SetStopSignal(signal string) error {
+	i.stopSignal = signal
+	return nil
+}
+
+func (i *Image) SetShell(shell ...string) error {
+	i.shell = shell
+	return nil
+}
+
+func (i *Image) SetOnBuild(triggers ...string) error {
+	i.onBuild = triggers
+	return nil
+}
+
 func (i *Image) Env(k string) (string, error) {
-	return i.env[k], nil
+	val, _ := imgutil.GetEnv(i.env, i.os, k)
+	return val, nil
 }
 
 func (i *Image) TopLayer() (string, error) {
 	return i.topLayerSha, nil
 }
 
+// DiffIDs returns the diff ids of the layers added to the image, in the order they were added.
+func (i *Image) DiffIDs() ([]string, error) {
+	var diffIDs []string
+	for _, path := range i.layers {
+		for diffID, layerPath := range i.layersMap {
+			if layerPath == path {
+				diffIDs = append(diffIDs, diffID)
+				break
+			}
+		}
+	}
+	return diffIDs, nil
+}
+
 func (i *Image) AddLayer(path string) error {
 	sha, err := shaForFile(path)
 	if err != nil {
@@ -163,7 +268,7 @@ func shaForFile(path string) (string, error) {
 func (i *Image) GetLayer(sha string) (io.ReadCloser, error) {
 	path, ok := i.layersMap[sha]
 	if !ok {
-		return nil, fmt.Errorf("failed to get layer with sha '%s'", sha)
+		return nil, imgutil.Errorf(imgutil.ErrLayerNotFound, "failed to get layer with sha '%s'", sha)
 	}
 
 	return os.Open(path)
@@ -172,13 +277,44 @@ func (i *Image) GetLayer(sha string) (io.ReadCloser, error) {
 func (i *Image) ReuseLayer(sha string) error {
 	prevLayer, ok := i.prevLayersMap[sha]
 	if !ok {
-		return fmt.Errorf("image does not have previous layer with sha '%s'", sha)
+		return imgutil.Errorf(imgutil.ErrLayerNotFound, "image does not have previous layer with sha '%s'", sha)
 	}
 	i.reusedLayers = append(i.reusedLayers, sha)
 	i.layersMap[sha] = prevLayer
 	return nil
 }
 
+func (i *Image) RemoveLabel(key string) error {
+	delete(i.labels, key)
+	return nil
+}
+
+func (i *Image) RemoveEnv(key string) error {
+	i.env = imgutil.RemoveEnv(i.env, i.os, key)
+	return nil
+}
+
+func (i *Image) RemoveLayer(sha string) error {
+	path, ok := i.layersMap[sha]
+	if !ok {
+		return imgutil.Errorf(imgutil.ErrLayerNotFound, "image has no layer with sha '%s'", sha)
+	}
+	delete(i.layersMap, sha)
+	for idx := range i.layers {
+		if i.layers[idx] == path {
+			i.layers = append(i.layers[:idx:idx], i.layers[idx+1:]...)
+			break
+		}
+	}
+	for idx := range i.reusedLayers {
+		if i.reusedLayers[idx] == sha {
+			i.reusedLayers = append(i.reusedLayers[:idx:idx], i.reusedLayers[idx+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
 func (i *Image) Save(additionalNames ...string) error {
 	var err error
 	i.layerDir, err = ioutil.TempDir("", "fake-image")
@@ -216,6 +352,17 @@ func (i *Image) Save(additionalNames ...string) error {
 	return nil
 }
 
+func (i *Image) SaveContext(ctx context.Context, additionalNames ...string) error {
+	if err := ctx.Err(); err != nil {
+		var errs []imgutil.SaveDiagnostic
+		for _, n := range append([]string{i.name}, additionalNames...) {
+			errs = append(errs, imgutil.SaveDiagnostic{ImageName: n, Cause: err})
+		}
+		return imgutil.SaveError{Errors: errs}
+	}
+	return i.Save(additionalNames...)
+}
+
 func (i *Image) copyLayer(path, newPath string) error {
 	src, err := os.Open(path)
 	if err != nil {
@@ -273,6 +420,18 @@ func (i *Image) Cmd() ([]string, error) {
 	return i.cmd, nil
 }
 
+func (i *Image) Healthcheck() *imgutil.HealthConfig {
+	return i.healthcheck
+}
+
+func (i *Image) Shell() []string {
+	return i.shell
+}
+
+func (i *Image) OnBuild() []string {
+	return i.onBuild
+}
+
 func (i *Image) ConfigLayerPath() string {
 	return i.layers[1]
 }
@@ -281,8 +440,8 @@ func (i *Image) ReusedLayers() []string {
 	return i.reusedLayers
 }
 
-func (i *Image) WorkingDir() string {
-	return i.workingDir
+func (i *Image) WorkingDir() (string, error) {
+	return i.workingDir, nil
 }
 
 func (i *Image) AddPreviousLayer(sha, path string) {
@@ -2,6 +2,8 @@ package fakes_test
 
 import (
 	"archive/tar"
+	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
@@ -16,10 +18,11 @@ import (
 
 	"github.com/buildpacks/imgutil"
 	"github.com/buildpacks/imgutil/fakes"
+	"github.com/buildpacks/imgutil/imgutiltest"
 	h "github.com/buildpacks/imgutil/testhelpers"
 )
 
-var localTestRegistry *h.DockerRegistry
+var localTestRegistry h.TestRegistry
 
 func newRepoName() string {
 	return "test-image-" + h.RandString(10)
@@ -28,11 +31,21 @@ func newRepoName() string {
 func TestFake(t *testing.T) {
 	rand.Seed(time.Now().UTC().UnixNano())
 
-	localTestRegistry = h.NewDockerRegistry()
+	localTestRegistry = h.NewTestRegistry()
 	localTestRegistry.Start(t)
 	defer localTestRegistry.Stop(t)
 
 	spec.Run(t, "FakeImage", testFake, spec.Parallel(), spec.Report(report.Terminal{}))
+
+	imgutiltest.Run(t, imgutiltest.Factory{
+		NewName: func(t *testing.T) string {
+			return newRepoName()
+		},
+		NewImage: func(t *testing.T, name, baseName, prevName string) imgutil.Image {
+			return fakes.NewImage(name, "", nil)
+		},
+		Unsupported: []imgutiltest.Feature{imgutiltest.Reopen, imgutiltest.TopLayer},
+	})
 }
 
 func testFake(t *testing.T, when spec.G, it spec.S) {
@@ -80,6 +93,107 @@ func testFake(t *testing.T, when spec.G, it spec.S) {
 		})
 	})
 
+	when("#SaveContext", func() {
+		it("doesn't save when the context is canceled", func() {
+			image := fakes.NewImage(newRepoName(), "", nil)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			err := image.SaveContext(ctx)
+			h.AssertError(t, err, "context canceled")
+			h.AssertEq(t, image.IsSaved(), false)
+		})
+	})
+
+	when("config getters", func() {
+		it("returns the values that were set", func() {
+			image := fakes.NewImage(newRepoName(), "", nil)
+
+			h.AssertNil(t, image.SetLabel("some.label", "some.value"))
+			h.AssertNil(t, image.SetEnv("SOME_KEY", "some-value"))
+			h.AssertNil(t, image.SetWorkingDir("/some/dir"))
+
+			labels, err := image.Labels()
+			h.AssertNil(t, err)
+			h.AssertEq(t, labels, map[string]string{"some.label": "some.value"})
+
+			envVars, err := image.EnvVars()
+			h.AssertNil(t, err)
+			h.AssertEq(t, envVars, map[string]string{"SOME_KEY": "some-value"})
+
+			workingDir, err := image.WorkingDir()
+			h.AssertNil(t, err)
+			h.AssertEq(t, workingDir, "/some/dir")
+		})
+	})
+
+	when("#SetPlatform", func() {
+		it("sets the os, os version and architecture", func() {
+			image := fakes.NewImage(newRepoName(), "", nil)
+			image.SetPlatform("windows", "10.0.17763.1040", "arm64")
+
+			imageOS, err := image.OS()
+			h.AssertNil(t, err)
+			h.AssertEq(t, imageOS, "windows")
+
+			osVersion, err := image.OSVersion()
+			h.AssertNil(t, err)
+			h.AssertEq(t, osVersion, "10.0.17763.1040")
+
+			arch, err := image.Architecture()
+			h.AssertNil(t, err)
+			h.AssertEq(t, arch, "arm64")
+		})
+	})
+
+	when("config setters", func() {
+		it("records the config values", func() {
+			image := fakes.NewImage(newRepoName(), "", nil)
+
+			h.AssertNil(t, image.SetUser("some-user"))
+			h.AssertNil(t, image.SetExposedPorts(map[string]struct{}{"8080/tcp": {}}))
+			h.AssertNil(t, image.SetHealthcheck(imgutil.HealthConfig{Test: []string{"NONE"}}))
+			h.AssertNil(t, image.SetShell("/bin/bash", "-c"))
+
+			user, err := image.User()
+			h.AssertNil(t, err)
+			h.AssertEq(t, user, "some-user")
+
+			ports, err := image.ExposedPorts()
+			h.AssertNil(t, err)
+			h.AssertEq(t, ports, map[string]struct{}{"8080/tcp": {}})
+
+			h.AssertEq(t, image.Healthcheck().Test, []string{"NONE"})
+			h.AssertEq(t, image.Shell(), []string{"/bin/bash", "-c"})
+		})
+	})
+
+	when("#RemoveLayer", func() {
+		it("removes an added layer", func() {
+			image := fakes.NewImage(newRepoName(), "", nil)
+
+			layerPath, err := createLayerTar(map[string]string{"/some/file": "some-contents"})
+			h.AssertNil(t, err)
+			defer os.Remove(layerPath)
+
+			h.AssertNil(t, image.AddLayerWithDiffID(layerPath, "some-diff-id"))
+			h.AssertNil(t, image.RemoveLayer("some-diff-id"))
+			h.AssertEq(t, image.NumberOfAddedLayers(), 0)
+
+			_, err = image.GetLayer("some-diff-id")
+			h.AssertError(t, err, "failed to get layer with sha 'some-diff-id'")
+		})
+
+		it("returns an error for a missing layer", func() {
+			image := fakes.NewImage(newRepoName(), "", nil)
+
+			err := image.RemoveLayer("some-bad-sha")
+			h.AssertError(t, err, "image has no layer with sha 'some-bad-sha'")
+			h.AssertEq(t, errors.Is(err, imgutil.ErrLayerNotFound), true)
+		})
+	})
+
 	when("#FindLayerWithPath", func() {
 		var (
 			image      *fakes.Image
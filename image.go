@@ -1,6 +1,7 @@
 package imgutil
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"strings"
@@ -30,25 +31,61 @@ type Image interface {
 	Name() string
 	Rename(name string)
 	Label(string) (string, error)
+	// Labels returns all labels of the image.
+	Labels() (map[string]string, error)
 	SetLabel(string, string) error
 	Env(key string) (string, error)
+	// EnvVars returns all environment variables of the image keyed by name.
+	EnvVars() (map[string]string, error)
+	Entrypoint() ([]string, error)
+	Cmd() ([]string, error)
+	WorkingDir() (string, error)
+	User() (string, error)
+	ExposedPorts() (map[string]struct{}, error)
+	Volumes() (map[string]struct{}, error)
+	StopSignal() (string, error)
 	SetEnv(string, string) error
 	SetEntrypoint(...string) error
 	SetWorkingDir(string) error
 	SetCmd(...string) error
-	Rebase(string, Image) error
+	SetUser(string) error
+	SetExposedPorts(map[string]struct{}) error
+	SetVolumes(map[string]struct{}) error
+	SetHealthcheck(HealthConfig) error
+	SetStopSignal(string) error
+	SetShell(...string) error
+	SetOnBuild(...string) error
+	// RemoveLabel removes the label with the given key. It is not an error if the label does not exist.
+	RemoveLabel(key string) error
+	// RemoveEnv removes the environment variable with the given key. It is not an error if the variable does not exist.
+	RemoveEnv(key string) error
+	// Rebase replaces the layers up to and including baseTopLayer with the layers of newBase, after making the checks
+	// of ops.
+	Rebase(baseTopLayer string, newBase Image, ops ...RebaseOption) error
+	// RebaseContext is Rebase, using ctx for any requests needed to rebase. Layers of a new base from another backend
+	// are read when the image is saved, through the GetLayer of newBase, so ctx doesn't apply to reading them.
+	RebaseContext(ctx context.Context, baseTopLayer string, newBase Image, ops ...RebaseOption) error
 	AddLayer(path string) error
 	AddLayerWithDiffID(path, diffID string) error
 	ReuseLayer(diffID string) error
+	// RemoveLayer removes a layer that was added on top of the base image. It is an error if no such layer exists.
+	RemoveLayer(diffID string) error
 	// TopLayer returns the diff id for the top layer
 	TopLayer() (string, error)
+	// DiffIDs returns the diff ids of the image's layers, bottom first. Together with GetLayer, it gives access to the
+	// layers of an image regardless of its backend.
+	DiffIDs() ([]string, error)
 	// Save saves the image as `Name()` and any additional names provided to this method.
 	Save(additionalNames ...string) error
+	// SaveContext is Save, using ctx for any requests needed to save.
+	SaveContext(ctx context.Context, additionalNames ...string) error
 	// Found tells whether the image exists in the repository by `Name()`.
 	Found() bool
 	// GetLayer retrieves layer by diff id. Returns a reader of the uncompressed contents of the layer.
 	GetLayer(diffID string) (io.ReadCloser, error)
 	Delete() error
+	// Cleanup removes any temporary files the image created. The image should not be used afterwards.
+	Cleanup() error
 	CreatedAt() (time.Time, error)
 	Identifier() (Identifier, error)
 	OS() (string, error)
@@ -57,3 +94,22 @@ type Image interface {
 }
 
 type Identifier fmt.Stringer
+
+// HealthConfig holds the settings of an image's HEALTHCHECK.
+type HealthConfig struct {
+	// Test is the check to perform, e.g. {"CMD", args...}, {"CMD-SHELL", command} or {"NONE"} to disable.
+	Test        []string
+	Interval    time.Duration
+	Timeout     time.Duration
+	StartPeriod time.Duration
+	Retries     int
+}
+
+// ImageIndex groups several per-platform images under a single name (manifest list or OCI image index).
+type ImageIndex interface {
+	Name() string
+	// Add adds an image to the index, describing it with a platform derived from its OS, OSVersion and Architecture.
+	Add(image Image) error
+	// Save saves the index as `Name()` and any additional names provided to this method.
+	Save(additionalNames ...string) error
+}
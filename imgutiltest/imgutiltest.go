@@ -0,0 +1,528 @@
+// Package imgutiltest provides a conformance suite for implementations of imgutil.Image.
+package imgutiltest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/fakes"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+// Feature is a behaviour covered by the suite that an implementation may not provide.
+type Feature string
+
+const (
+	// Reopen is reading an image back after it was saved, which base and previous images rely on.
+	Reopen Feature = "reopen"
+	// TopLayer is TopLayer reporting the diff id of the most recently added layer.
+	TopLayer Feature = "top-layer"
+)
+
+// Factory creates the images exercised by Run.
+type Factory struct {
+	// NewName returns a name for an image that does not exist yet.
+	NewName func(t *testing.T) string
+	// NewImage returns an image called name. When not empty, baseName and prevName name images
+	// previously saved by the suite that must be used as base and previous image.
+	NewImage func(t *testing.T, name, baseName, prevName string) imgutil.Image
+	// LayerOS is the OS that layers are created for, defaults to linux.
+	LayerOS string
+	// Unsupported lists features whose specs are skipped.
+	Unsupported []Feature
+}
+
+func (f Factory) supports(feature Feature) bool {
+	for _, unsupported := range f.Unsupported {
+		if unsupported == feature {
+			return false
+		}
+	}
+	return true
+}
+
+// Run runs the conformance suite against the images created by f.
+func Run(t *testing.T, f Factory) {
+	if f.LayerOS == "" {
+		f.LayerOS = "linux"
+	}
+
+	spec.Run(t, "imgutil.Image", func(t *testing.T, when spec.G, it spec.S) {
+		testImage(t, when, it, f)
+	}, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testImage(t *testing.T, when spec.G, it spec.S, f Factory) {
+	var layerPaths []string
+
+	newLayer := func(path, contents string) string {
+		layerPath, err := h.CreateSingleFileLayerTar(path, contents, f.LayerOS)
+		h.AssertNil(t, err)
+		layerPaths = append(layerPaths, layerPath)
+		return layerPath
+	}
+
+	it.After(func() {
+		for _, layerPath := range layerPaths {
+			os.Remove(layerPath)
+		}
+	})
+
+	when("labels", func() {
+		it("sets, gets and removes labels", func() {
+			img := f.NewImage(t, f.NewName(t), "", "")
+
+			h.AssertNil(t, img.SetLabel("some.label", "some.value"))
+			h.AssertNil(t, img.SetLabel("other.label", "other.value"))
+			h.AssertNil(t, img.SetLabel("some.label", "new.value"))
+
+			val, err := img.Label("some.label")
+			h.AssertNil(t, err)
+			h.AssertEq(t, val, "new.value")
+
+			h.AssertNil(t, img.RemoveLabel("other.label"))
+			h.AssertNil(t, img.RemoveLabel("missing.label"))
+
+			labels, err := img.Labels()
+			h.AssertNil(t, err)
+			h.AssertEq(t, labels, map[string]string{"some.label": "new.value"})
+		})
+
+		it("returns an empty string for a missing label", func() {
+			img := f.NewImage(t, f.NewName(t), "", "")
+
+			val, err := img.Label("missing.label")
+			h.AssertNil(t, err)
+			h.AssertEq(t, val, "")
+		})
+	})
+
+	when("env", func() {
+		it("replaces values in place and preserves '='", func() {
+			img := f.NewImage(t, f.NewName(t), "", "")
+
+			h.AssertNil(t, img.SetEnv("SOME_KEY", "old"))
+			h.AssertNil(t, img.SetEnv("OTHER_KEY", "other"))
+			h.AssertNil(t, img.SetEnv("SOME_KEY", "new=value"))
+
+			val, err := img.Env("SOME_KEY")
+			h.AssertNil(t, err)
+			h.AssertEq(t, val, "new=value")
+
+			envVars, err := img.EnvVars()
+			h.AssertNil(t, err)
+			h.AssertEq(t, envVars, map[string]string{"SOME_KEY": "new=value", "OTHER_KEY": "other"})
+		})
+
+		it("removes values", func() {
+			img := f.NewImage(t, f.NewName(t), "", "")
+
+			h.AssertNil(t, img.SetEnv("SOME_KEY", "some-value"))
+			h.AssertNil(t, img.RemoveEnv("SOME_KEY"))
+			h.AssertNil(t, img.RemoveEnv("MISSING_KEY"))
+
+			val, err := img.Env("SOME_KEY")
+			h.AssertNil(t, err)
+			h.AssertEq(t, val, "")
+		})
+	})
+
+	when("layers", func() {
+		it("returns an error when removing a layer that was not added", func() {
+			img := f.NewImage(t, f.NewName(t), "", "")
+
+			assertErrorKind(t, img.RemoveLayer("sha256:"+h.RandString(64)), imgutil.ErrLayerNotFound)
+		})
+
+		it("returns an error when reusing a layer without a previous image", func() {
+			img := f.NewImage(t, f.NewName(t), "", "")
+
+			assertErrorKind(t, img.ReuseLayer("sha256:"+h.RandString(64)), imgutil.ErrLayerNotFound)
+		})
+
+		it("lists the diff ids of its layers, bottom first", func() {
+			img := f.NewImage(t, f.NewName(t), "", "")
+
+			layer1Path := newLayer("/layer-1.txt", "layer-1")
+			h.AssertNil(t, img.AddLayer(layer1Path))
+			layer2Path := newLayer("/layer-2.txt", "layer-2")
+			h.AssertNil(t, img.AddLayer(layer2Path))
+
+			diffIDs, err := img.DiffIDs()
+			h.AssertNil(t, err)
+			h.AssertEq(t, diffIDs, []string{h.FileDiffID(t, layer1Path), h.FileDiffID(t, layer2Path)})
+		})
+
+		if f.supports(TopLayer) {
+			it("reports the last added layer as the top layer", func() {
+				img := f.NewImage(t, f.NewName(t), "", "")
+
+				h.AssertNil(t, img.AddLayer(newLayer("/layer-1.txt", "layer-1")))
+				layer2Path := newLayer("/layer-2.txt", "layer-2")
+				h.AssertNil(t, img.AddLayer(layer2Path))
+
+				topLayer, err := img.TopLayer()
+				h.AssertNil(t, err)
+				h.AssertEq(t, topLayer, h.FileDiffID(t, layer2Path))
+			})
+		}
+	})
+
+	when("#SaveContext", func() {
+		it("returns an imgutil.SaveError naming every image and saves nothing when the context is canceled", func() {
+			name := f.NewName(t)
+			additionalName := f.NewName(t)
+			img := f.NewImage(t, name, "", "")
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			err := img.SaveContext(ctx, additionalName)
+			saveErr, ok := err.(imgutil.SaveError)
+			h.AssertEq(t, ok, true)
+
+			var names []string
+			for _, diagnostic := range saveErr.Errors {
+				names = append(names, diagnostic.ImageName)
+			}
+			h.AssertContains(t, names, name, additionalName)
+			if f.supports(Reopen) {
+				h.AssertEq(t, img.Found(), false)
+			}
+		})
+	})
+
+	if !f.supports(Reopen) {
+		return
+	}
+
+	when("saved images", func() {
+		it("keeps config and layers", func() {
+			name := f.NewName(t)
+			img := f.NewImage(t, name, "", "")
+
+			h.AssertNil(t, img.SetLabel("some.label", "some.value"))
+			h.AssertNil(t, img.SetEnv("SOME_KEY", "some=value"))
+			layerPath := newLayer("/layer.txt", "layer")
+			h.AssertNil(t, img.AddLayer(layerPath))
+			h.AssertNil(t, img.Save())
+
+			saved := f.NewImage(t, name, name, "")
+
+			val, err := saved.Label("some.label")
+			h.AssertNil(t, err)
+			h.AssertEq(t, val, "some.value")
+
+			val, err = saved.Env("SOME_KEY")
+			h.AssertNil(t, err)
+			h.AssertEq(t, val, "some=value")
+
+			assertLayerContents(t, saved, layerPath)
+		})
+
+		it("saves additional names", func() {
+			name := f.NewName(t)
+			additionalNames := []string{f.NewName(t), f.NewName(t)}
+			img := f.NewImage(t, name, "", "")
+
+			h.AssertNil(t, img.SetLabel("some.label", "some.value"))
+			h.AssertNil(t, img.Save(additionalNames...))
+
+			for _, additionalName := range additionalNames {
+				saved := f.NewImage(t, additionalName, additionalName, "")
+
+				val, err := saved.Label("some.label")
+				h.AssertNil(t, err)
+				h.AssertEq(t, val, "some.value")
+			}
+		})
+
+		it("does not allow removing base image layers", func() {
+			baseName := f.NewName(t)
+			baseImage := f.NewImage(t, baseName, "", "")
+			baseLayerPath := newLayer("/base.txt", "base")
+			h.AssertNil(t, baseImage.AddLayer(baseLayerPath))
+			h.AssertNil(t, baseImage.Save())
+
+			img := f.NewImage(t, f.NewName(t), baseName, "")
+
+			assertErrorKind(t, img.RemoveLayer(h.FileDiffID(t, baseLayerPath)), imgutil.ErrBaseLayer)
+		})
+	})
+
+	when("base images", func() {
+		var (
+			baseName       string
+			baseLayer1Path string
+			baseLayer2Path string
+		)
+
+		it.Before(func() {
+			baseName = f.NewName(t)
+			baseImage := f.NewImage(t, baseName, "", "")
+			h.AssertNil(t, baseImage.SetLabel("base.label", "base.value"))
+			h.AssertNil(t, baseImage.SetEnv("BASE_KEY", "base-value"))
+			baseLayer1Path = newLayer("/base-1.txt", "base-1")
+			h.AssertNil(t, baseImage.AddLayer(baseLayer1Path))
+			baseLayer2Path = newLayer("/base-2.txt", "base-2")
+			h.AssertNil(t, baseImage.AddLayer(baseLayer2Path))
+			h.AssertNil(t, baseImage.Save())
+		})
+
+		it("keeps the labels and env of the base image", func() {
+			name := f.NewName(t)
+			img := f.NewImage(t, name, baseName, "")
+			h.AssertNil(t, img.SetLabel("some.label", "some.value"))
+			h.AssertNil(t, img.SetEnv("SOME_KEY", "some-value"))
+			h.AssertNil(t, img.Save())
+
+			saved := f.NewImage(t, name, name, "")
+
+			labels, err := saved.Labels()
+			h.AssertNil(t, err)
+			h.AssertEq(t, labels["base.label"], "base.value")
+			h.AssertEq(t, labels["some.label"], "some.value")
+
+			envVars, err := saved.EnvVars()
+			h.AssertNil(t, err)
+			h.AssertEq(t, envVars["BASE_KEY"], "base-value")
+			h.AssertEq(t, envVars["SOME_KEY"], "some-value")
+		})
+
+		it("adds layers on top of the base image layers", func() {
+			name := f.NewName(t)
+			img := f.NewImage(t, name, baseName, "")
+			layerPath := newLayer("/layer.txt", "layer")
+			h.AssertNil(t, img.AddLayer(layerPath))
+			h.AssertNil(t, img.Save())
+
+			saved := f.NewImage(t, name, name, "")
+			diffIDs, err := saved.DiffIDs()
+			h.AssertNil(t, err)
+			h.AssertEq(t, diffIDs, []string{h.FileDiffID(t, baseLayer1Path), h.FileDiffID(t, baseLayer2Path), h.FileDiffID(t, layerPath)})
+
+			if f.supports(TopLayer) {
+				topLayer, err := saved.TopLayer()
+				h.AssertNil(t, err)
+				h.AssertEq(t, topLayer, h.FileDiffID(t, layerPath))
+			}
+		})
+
+		it("reuses layers of the previous image in order with added layers", func() {
+			prevName := f.NewName(t)
+			prevImage := f.NewImage(t, prevName, baseName, "")
+			prevLayer1Path := newLayer("/layer-1.txt", "old-layer-1")
+			h.AssertNil(t, prevImage.AddLayer(prevLayer1Path))
+			prevLayer2Path := newLayer("/layer-2.txt", "old-layer-2")
+			h.AssertNil(t, prevImage.AddLayer(prevLayer2Path))
+			h.AssertNil(t, prevImage.Save())
+
+			name := f.NewName(t)
+			img := f.NewImage(t, name, baseName, prevName)
+			layer1Path := newLayer("/layer-1.txt", "new-layer-1")
+			h.AssertNil(t, img.AddLayer(layer1Path))
+			h.AssertNil(t, img.ReuseLayer(h.FileDiffID(t, prevLayer2Path)))
+			h.AssertNil(t, img.Save())
+
+			saved := f.NewImage(t, name, name, "")
+			diffIDs, err := saved.DiffIDs()
+			h.AssertNil(t, err)
+			h.AssertEq(t, diffIDs, []string{
+				h.FileDiffID(t, baseLayer1Path),
+				h.FileDiffID(t, baseLayer2Path),
+				h.FileDiffID(t, layer1Path),
+				h.FileDiffID(t, prevLayer2Path),
+			})
+			assertLayerContents(t, saved, prevLayer2Path)
+		})
+	})
+
+	when("#ReuseLayer", func() {
+		it("reuses a layer from the previous image", func() {
+			prevName := f.NewName(t)
+			prevImage := f.NewImage(t, prevName, "", "")
+			layer1Path := newLayer("/layer-1.txt", "old-layer-1")
+			layer2Path := newLayer("/layer-2.txt", "old-layer-2")
+			h.AssertNil(t, prevImage.AddLayer(layer1Path))
+			h.AssertNil(t, prevImage.AddLayer(layer2Path))
+			h.AssertNil(t, prevImage.Save())
+
+			name := f.NewName(t)
+			img := f.NewImage(t, name, "", prevName)
+			h.AssertNil(t, img.ReuseLayer(h.FileDiffID(t, layer2Path)))
+			h.AssertNil(t, img.Save())
+
+			saved := f.NewImage(t, name, name, "")
+			assertLayerContents(t, saved, layer2Path)
+			_, err := saved.GetLayer(h.FileDiffID(t, layer1Path))
+			assertErrorKind(t, err, imgutil.ErrLayerNotFound)
+		})
+
+		it("returns an error for a layer missing from the previous image", func() {
+			prevName := f.NewName(t)
+			prevImage := f.NewImage(t, prevName, "", "")
+			h.AssertNil(t, prevImage.AddLayer(newLayer("/layer.txt", "old-layer")))
+			h.AssertNil(t, prevImage.Save())
+
+			img := f.NewImage(t, f.NewName(t), "", prevName)
+
+			assertErrorKind(t, img.ReuseLayer("sha256:"+h.RandString(64)), imgutil.ErrLayerNotFound)
+		})
+	})
+
+	when("#Cleanup", func() {
+		it("leaves saved images readable", func() {
+			prevName := f.NewName(t)
+			prevImage := f.NewImage(t, prevName, "", "")
+			layerPath := newLayer("/layer.txt", "old-layer")
+			h.AssertNil(t, prevImage.AddLayer(layerPath))
+			h.AssertNil(t, prevImage.Save())
+			h.AssertNil(t, prevImage.Cleanup())
+
+			name := f.NewName(t)
+			img := f.NewImage(t, name, "", prevName)
+			h.AssertNil(t, img.ReuseLayer(h.FileDiffID(t, layerPath)))
+			h.AssertNil(t, img.Save())
+			h.AssertNil(t, img.Cleanup())
+
+			saved := f.NewImage(t, name, name, "")
+			assertLayerContents(t, saved, layerPath)
+		})
+	})
+
+	when("#Rebase", func() {
+		it("swaps the base layers", func() {
+			oldBaseName := f.NewName(t)
+			oldBase := f.NewImage(t, oldBaseName, "", "")
+			oldBaseLayerPath := newLayer("/base.txt", "old-base")
+			h.AssertNil(t, oldBase.AddLayer(oldBaseLayerPath))
+			h.AssertNil(t, oldBase.Save())
+
+			newBaseName := f.NewName(t)
+			newBase := f.NewImage(t, newBaseName, "", "")
+			newBaseLayerPath := newLayer("/base.txt", "new-base")
+			h.AssertNil(t, newBase.AddLayer(newBaseLayerPath))
+			h.AssertNil(t, newBase.Save())
+
+			name := f.NewName(t)
+			img := f.NewImage(t, name, oldBaseName, "")
+			appLayerPath := newLayer("/app.txt", "app")
+			h.AssertNil(t, img.AddLayer(appLayerPath))
+			h.AssertNil(t, img.Save())
+
+			img = f.NewImage(t, name, name, "")
+			newBase = f.NewImage(t, newBaseName, newBaseName, "")
+			h.AssertNil(t, img.Rebase(h.FileDiffID(t, oldBaseLayerPath), newBase))
+			h.AssertNil(t, img.Save())
+
+			rebased := f.NewImage(t, name, name, "")
+			diffIDs, err := rebased.DiffIDs()
+			h.AssertNil(t, err)
+			h.AssertEq(t, diffIDs, []string{h.FileDiffID(t, newBaseLayerPath), h.FileDiffID(t, appLayerPath)})
+			assertLayerContents(t, rebased, newBaseLayerPath)
+			assertLayerContents(t, rebased, appLayerPath)
+			_, err = rebased.GetLayer(h.FileDiffID(t, oldBaseLayerPath))
+			assertErrorKind(t, err, imgutil.ErrLayerNotFound)
+		})
+
+		it("swaps the base layers for a base from another backend", func() {
+			oldBaseName := f.NewName(t)
+			oldBase := f.NewImage(t, oldBaseName, "", "")
+			oldBaseLayerPath := newLayer("/base.txt", "old-base")
+			h.AssertNil(t, oldBase.AddLayer(oldBaseLayerPath))
+			h.AssertNil(t, oldBase.Save())
+
+			name := f.NewName(t)
+			img := f.NewImage(t, name, oldBaseName, "")
+			appLayerPath := newLayer("/app.txt", "app")
+			h.AssertNil(t, img.AddLayer(appLayerPath))
+			h.AssertNil(t, img.Save())
+
+			newBase := fakes.NewImage("some-fake-base", "", nil)
+			newBaseLayerPath := newLayer("/base.txt", "new-base")
+			h.AssertNil(t, newBase.AddLayer(newBaseLayerPath))
+
+			img = f.NewImage(t, name, name, "")
+			h.AssertNil(t, img.Rebase(h.FileDiffID(t, oldBaseLayerPath), newBase))
+			h.AssertNil(t, img.Save())
+
+			rebased := f.NewImage(t, name, name, "")
+			diffIDs, err := rebased.DiffIDs()
+			h.AssertNil(t, err)
+			h.AssertEq(t, diffIDs, []string{h.FileDiffID(t, newBaseLayerPath), h.FileDiffID(t, appLayerPath)})
+			assertLayerContents(t, rebased, newBaseLayerPath)
+			assertLayerContents(t, rebased, appLayerPath)
+		})
+
+		it("doesn't switch to a base that fails the checks", func() {
+			oldBaseName := f.NewName(t)
+			oldBase := f.NewImage(t, oldBaseName, "", "")
+			oldBaseLayerPath := newLayer("/base.txt", "old-base")
+			h.AssertNil(t, oldBase.AddLayer(oldBaseLayerPath))
+			h.AssertNil(t, oldBase.SetLabel("some-stack-label", "some-stack"))
+			h.AssertNil(t, oldBase.Save())
+
+			name := f.NewName(t)
+			img := f.NewImage(t, name, oldBaseName, "")
+			appLayerPath := newLayer("/app.txt", "app")
+			h.AssertNil(t, img.AddLayer(appLayerPath))
+
+			newBase := fakes.NewImage("some-fake-base", "", nil)
+			newBase.SetPlatform("some-os", "", "some-arch")
+			h.AssertNil(t, newBase.SetLabel("some-stack-label", "other-stack"))
+
+			err := img.Rebase(h.FileDiffID(t, oldBaseLayerPath), newBase, imgutil.MatchPlatform(), imgutil.MatchLabels("some-stack-label"))
+			h.AssertEq(t, errors.Is(err, imgutil.ErrPlatformMismatch), true)
+			h.AssertEq(t, errors.Is(err, imgutil.ErrLabelMismatch), true)
+
+			diffIDs, err := img.DiffIDs()
+			h.AssertNil(t, err)
+			h.AssertEq(t, diffIDs, []string{h.FileDiffID(t, oldBaseLayerPath), h.FileDiffID(t, appLayerPath)})
+		})
+	})
+}
+
+func assertError(t *testing.T, err error) {
+	t.Helper()
+
+	if err == nil {
+		t.Fatal("expected an error but got nil")
+	}
+}
+
+// assertErrorKind asserts that err is an *imgutil.Error of the given kind.
+func assertErrorKind(t *testing.T, err error, kind error) {
+	t.Helper()
+
+	assertError(t, err)
+	var imgutilErr *imgutil.Error
+	if !errors.As(err, &imgutilErr) {
+		t.Fatalf("expected an *imgutil.Error but got %T: %s", err, err)
+	}
+	if !errors.Is(err, kind) {
+		t.Fatalf("expected an error of kind '%s' but got: %s", kind, err)
+	}
+}
+
+func assertLayerContents(t *testing.T, img imgutil.Image, layerPath string) {
+	t.Helper()
+
+	expected, err := ioutil.ReadFile(layerPath)
+	h.AssertNil(t, err)
+
+	rc, err := img.GetLayer(h.FileDiffID(t, layerPath))
+	h.AssertNil(t, err)
+	defer rc.Close()
+
+	actual, err := ioutil.ReadAll(rc)
+	h.AssertNil(t, err)
+	h.AssertEq(t, bytes.Equal(actual, expected), true)
+}
@@ -0,0 +1,600 @@
+// Package v1image implements the parts of imgutil.Image that are the same for every backend that builds a
+// go-containerregistry v1.Image, i.e. remote, layout and tarball. Backends embed an Image and add reading and saving
+// images.
+package v1image
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/pkg/errors"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/layer"
+)
+
+// Image is an image being built on top of a v1.Image.
+type Image struct {
+	// V1Image is the image as built so far.
+	V1Image v1.Image
+	// BaseLayers is the number of layers of V1Image that belong to its base image, which can't be removed.
+	BaseLayers int
+	// PrevLayers are the layers of the previous image, which can be reused.
+	PrevLayers []v1.Layer
+	// Describe returns how error messages refer to the image, e.g. "image 'some-name'".
+	Describe func() string
+}
+
+// Empty returns an image without layers for the given platform, which defaults to linux/amd64.
+func Empty(platform *v1.Platform) (v1.Image, error) {
+	cfg := &v1.ConfigFile{
+		OS:           "linux",
+		Architecture: "amd64",
+		RootFS: v1.RootFS{
+			Type:    "layers",
+			DiffIDs: []v1.Hash{},
+		},
+	}
+	if platform != nil {
+		cfg.OS = platform.OS
+		cfg.Architecture = platform.Architecture
+		cfg.OSVersion = platform.OSVersion
+	}
+	return mutate.ConfigFile(empty.Image, cfg)
+}
+
+func (i *Image) Label(key string) (string, error) {
+	cfg, err := i.V1Image.ConfigFile()
+	if err != nil || cfg == nil {
+		return "", imgutil.Errorf(imgutil.ErrInvalidImage, "failed to get config file for %s", i.Describe())
+	}
+	labels := cfg.Config.Labels
+	return labels[key], nil
+}
+
+func (i *Image) Env(key string) (string, error) {
+	cfg, err := i.V1Image.ConfigFile()
+	if err != nil || cfg == nil {
+		return "", imgutil.Errorf(imgutil.ErrInvalidImage, "failed to get config file for %s", i.Describe())
+	}
+	val, _ := imgutil.GetEnv(cfg.Config.Env, cfg.OS, key)
+	return val, nil
+}
+
+func (i *Image) Labels() (map[string]string, error) {
+	cfg, err := i.configFile()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Config.Labels == nil {
+		return map[string]string{}, nil
+	}
+	return cfg.Config.Labels, nil
+}
+
+func (i *Image) EnvVars() (map[string]string, error) {
+	cfg, err := i.configFile()
+	if err != nil {
+		return nil, err
+	}
+	return imgutil.EnvMap(cfg.Config.Env, cfg.OS), nil
+}
+
+func (i *Image) Entrypoint() ([]string, error) {
+	cfg, err := i.configFile()
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Config.Entrypoint, nil
+}
+
+func (i *Image) Cmd() ([]string, error) {
+	cfg, err := i.configFile()
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Config.Cmd, nil
+}
+
+func (i *Image) WorkingDir() (string, error) {
+	cfg, err := i.configFile()
+	if err != nil {
+		return "", err
+	}
+	return cfg.Config.WorkingDir, nil
+}
+
+func (i *Image) User() (string, error) {
+	cfg, err := i.configFile()
+	if err != nil {
+		return "", err
+	}
+	return cfg.Config.User, nil
+}
+
+func (i *Image) ExposedPorts() (map[string]struct{}, error) {
+	cfg, err := i.configFile()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Config.ExposedPorts == nil {
+		return map[string]struct{}{}, nil
+	}
+	return cfg.Config.ExposedPorts, nil
+}
+
+func (i *Image) Volumes() (map[string]struct{}, error) {
+	cfg, err := i.configFile()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Config.Volumes == nil {
+		return map[string]struct{}{}, nil
+	}
+	return cfg.Config.Volumes, nil
+}
+
+func (i *Image) StopSignal() (string, error) {
+	cfg, err := i.configFile()
+	if err != nil {
+		return "", err
+	}
+	return cfg.Config.StopSignal, nil
+}
+
+// configFile returns a copy of the config file that is safe to hand out to callers.
+func (i *Image) configFile() (*v1.ConfigFile, error) {
+	cfg, err := i.V1Image.ConfigFile()
+	if err != nil || cfg == nil {
+		return nil, imgutil.Errorf(imgutil.ErrInvalidImage, "failed to get config file for %s", i.Describe())
+	}
+	return cfg.DeepCopy(), nil
+}
+
+func (i *Image) OS() (string, error) {
+	cfg, err := i.V1Image.ConfigFile()
+	if err != nil || cfg == nil || cfg.OS == "" {
+		return "", imgutil.Errorf(imgutil.ErrInvalidImage, "failed to get OS from config file for %s", i.Describe())
+	}
+	return cfg.OS, nil
+}
+
+func (i *Image) OSVersion() (string, error) {
+	cfg, err := i.V1Image.ConfigFile()
+	if err != nil || cfg == nil {
+		return "", imgutil.Errorf(imgutil.ErrInvalidImage, "failed to get OSVersion from config file for %s", i.Describe())
+	}
+	return cfg.OSVersion, nil
+}
+
+func (i *Image) Architecture() (string, error) {
+	cfg, err := i.V1Image.ConfigFile()
+	if err != nil || cfg == nil || cfg.Architecture == "" {
+		return "", imgutil.Errorf(imgutil.ErrInvalidImage, "failed to get Architecture from config file for %s", i.Describe())
+	}
+	return cfg.Architecture, nil
+}
+
+func (i *Image) CreatedAt() (time.Time, error) {
+	configFile, err := i.V1Image.ConfigFile()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get createdAt time for %s: %s", i.Describe(), err)
+	}
+	return configFile.Created.UTC(), nil
+}
+
+func (i *Image) SetLabel(key, val string) error {
+	return i.updateConfig(func(config *v1.Config, os string) bool {
+		if config.Labels == nil {
+			config.Labels = map[string]string{}
+		}
+		config.Labels[key] = val
+		return true
+	})
+}
+
+func (i *Image) SetEnv(key, val string) error {
+	return i.updateConfig(func(config *v1.Config, os string) bool {
+		config.Env = imgutil.SetEnv(config.Env, os, key, val)
+		return true
+	})
+}
+
+func (i *Image) SetWorkingDir(dir string) error {
+	return i.updateConfig(func(config *v1.Config, os string) bool {
+		config.WorkingDir = dir
+		return true
+	})
+}
+
+func (i *Image) SetEntrypoint(ep ...string) error {
+	return i.updateConfig(func(config *v1.Config, os string) bool {
+		config.Entrypoint = ep
+		return true
+	})
+}
+
+func (i *Image) SetCmd(cmd ...string) error {
+	return i.updateConfig(func(config *v1.Config, os string) bool {
+		config.Cmd = cmd
+		return true
+	})
+}
+
+func (i *Image) SetUser(user string) error {
+	return i.updateConfig(func(config *v1.Config, os string) bool {
+		config.User = user
+		return true
+	})
+}
+
+func (i *Image) SetExposedPorts(ports map[string]struct{}) error {
+	return i.updateConfig(func(config *v1.Config, os string) bool {
+		config.ExposedPorts = map[string]struct{}{}
+		for port := range ports {
+			config.ExposedPorts[port] = struct{}{}
+		}
+		return true
+	})
+}
+
+func (i *Image) SetVolumes(volumes map[string]struct{}) error {
+	return i.updateConfig(func(config *v1.Config, os string) bool {
+		config.Volumes = map[string]struct{}{}
+		for volume := range volumes {
+			config.Volumes[volume] = struct{}{}
+		}
+		return true
+	})
+}
+
+func (i *Image) SetHealthcheck(healthcheck imgutil.HealthConfig) error {
+	return i.updateConfig(func(config *v1.Config, os string) bool {
+		config.Healthcheck = &v1.HealthConfig{
+			Test:        healthcheck.Test,
+			Interval:    healthcheck.Interval,
+			Timeout:     healthcheck.Timeout,
+			StartPeriod: healthcheck.StartPeriod,
+			Retries:     healthcheck.Retries,
+		}
+		return true
+	})
+}
+
+func (i *Image) SetStopSignal(signal string) error {
+	return i.updateConfig(func(config *v1.Config, os string) bool {
+		config.StopSignal = signal
+		return true
+	})
+}
+
+func (i *Image) SetShell(shell ...string) error {
+	return i.updateConfig(func(config *v1.Config, os string) bool {
+		config.Shell = shell
+		return true
+	})
+}
+
+func (i *Image) SetOnBuild(triggers ...string) error {
+	return i.updateConfig(func(config *v1.Config, os string) bool {
+		config.OnBuild = triggers
+		return true
+	})
+}
+
+func (i *Image) RemoveLabel(key string) error {
+	return i.updateConfig(func(config *v1.Config, os string) bool {
+		if _, ok := config.Labels[key]; !ok {
+			return false
+		}
+		delete(config.Labels, key)
+		return true
+	})
+}
+
+func (i *Image) RemoveEnv(key string) error {
+	return i.updateConfig(func(config *v1.Config, os string) bool {
+		env := imgutil.RemoveEnv(config.Env, os, key)
+		if len(env) == len(config.Env) {
+			return false
+		}
+		config.Env = env
+		return true
+	})
+}
+
+// updateConfig replaces the config of the image with a copy changed by update, unless update reports that it didn't
+// change anything. update is given the OS of the image, which the format of some values depends on.
+func (i *Image) updateConfig(update func(config *v1.Config, os string) bool) error {
+	configFile, err := i.V1Image.ConfigFile()
+	if err != nil {
+		return err
+	}
+	config := *configFile.Config.DeepCopy()
+	if !update(&config, configFile.OS) {
+		return nil
+	}
+	i.V1Image, err = mutate.Config(i.V1Image, config)
+	return err
+}
+
+func (i *Image) TopLayer() (string, error) {
+	all, err := i.V1Image.Layers()
+	if err != nil {
+		return "", err
+	}
+	if len(all) == 0 {
+		return "", imgutil.Errorf(imgutil.ErrLayerNotFound, "%s has no layers", i.Describe())
+	}
+	topLayer := all[len(all)-1]
+	hex, err := topLayer.DiffID()
+	if err != nil {
+		return "", err
+	}
+	return hex.String(), nil
+}
+
+func (i *Image) DiffIDs() ([]string, error) {
+	cfg, err := i.V1Image.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	diffIDs := make([]string, len(cfg.RootFS.DiffIDs))
+	for idx, diffID := range cfg.RootFS.DiffIDs {
+		diffIDs[idx] = diffID.String()
+	}
+	return diffIDs, nil
+}
+
+func (i *Image) GetLayer(diffID string) (io.ReadCloser, error) {
+	layers, err := i.V1Image.Layers()
+	if err != nil {
+		return nil, err
+	}
+
+	layer, err := findLayerWithDiffID(layers, diffID)
+	if err != nil {
+		return nil, err
+	}
+
+	return layer.Uncompressed()
+}
+
+func (i *Image) AddLayer(path string) error {
+	layer, err := tarball.LayerFromFile(path)
+	if err != nil {
+		return err
+	}
+	i.V1Image, err = mutate.AppendLayers(i.V1Image, layer)
+	if err != nil {
+		return errors.Wrap(err, "add layer")
+	}
+	return nil
+}
+
+// AddLayerWithDiffID is AddLayer, the diff id is only used to optimize adding layers to local images.
+func (i *Image) AddLayerWithDiffID(path, diffID string) error {
+	return i.AddLayer(path)
+}
+
+func (i *Image) ReuseLayer(diffID string) error {
+	layer, err := findLayerWithDiffID(i.PrevLayers, diffID)
+	if err != nil {
+		return err
+	}
+	i.V1Image, err = mutate.AppendLayers(i.V1Image, layer)
+	return err
+}
+
+func findLayerWithDiffID(layers []v1.Layer, diffID string) (v1.Layer, error) {
+	for _, layer := range layers {
+		dID, err := layer.DiffID()
+		if err != nil {
+			return nil, errors.Wrap(err, "get diff ID for previous image layer")
+		}
+		if diffID == dID.String() {
+			return layer, nil
+		}
+	}
+	return nil, imgutil.Errorf(imgutil.ErrLayerNotFound, `previous image did not have layer with diff id '%s'`, diffID)
+}
+
+// Cleanup does nothing, the image doesn't create temporary files.
+func (i *Image) Cleanup() error {
+	return nil
+}
+
+func (i *Image) RemoveLayer(diffID string) error {
+	layers, err := i.V1Image.Layers()
+	if err != nil {
+		return err
+	}
+	for idx := len(layers) - 1; idx >= 0; idx-- {
+		layerDiffID, err := layers[idx].DiffID()
+		if err != nil {
+			return err
+		}
+		if layerDiffID.String() != diffID {
+			continue
+		}
+		if idx < i.BaseLayers {
+			return imgutil.Errorf(imgutil.ErrBaseLayer, "layer with diff id '%s' belongs to the base of %s and cannot be removed", diffID, i.Describe())
+		}
+		i.V1Image, err = withoutLayer(i.V1Image, layers, idx)
+		return err
+	}
+	return imgutil.Errorf(imgutil.ErrLayerNotFound, "%s has no layer with diff id '%s'", i.Describe(), diffID)
+}
+
+// RebaseOnto makes the checks of ops and replaces the layers of img, the image of the backend that embeds i, up to and
+// including baseTopLayer with the layers of newBase. A new base of a backend that embeds an Image is used as it is, so
+// its layers keep their compressed blobs, e.g. blobs of a registry that can be mounted rather than uploaded again.
+// Layers of a new base from another backend are read through its GetLayer when the image is saved.
+func (i *Image) RebaseOnto(ctx context.Context, img imgutil.Image, baseTopLayer string, newBase imgutil.Image, ops ...imgutil.RebaseOption) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := imgutil.ValidateRebase(img, newBase, ops...); err != nil {
+		return err
+	}
+
+	var newBaseImage v1.Image
+	if embedded, ok := newBase.(embedder); ok {
+		newBaseImage = embedded.image().V1Image
+	} else {
+		var err error
+		if newBaseImage, err = layer.V1Image(newBase); err != nil {
+			return err
+		}
+	}
+	return i.switchBase(baseTopLayer, newBaseImage)
+}
+
+// embedder is implemented by the images of the backends that embed an Image.
+type embedder interface {
+	image() *Image
+}
+
+func (i *Image) image() *Image {
+	return i
+}
+
+// switchBase replaces the layers of the image up to and including baseTopLayer with the layers of newBase.
+func (i *Image) switchBase(baseTopLayer string, newBase v1.Image) error {
+	newImage, err := mutate.Rebase(i.V1Image, &subImage{img: i.V1Image, topDiffID: baseTopLayer}, newBase)
+	if err != nil {
+		return errors.Wrap(err, "rebase")
+	}
+	baseLayers, err := LayerCount(newBase)
+	if err != nil {
+		return err
+	}
+	i.V1Image = newImage
+	i.BaseLayers = baseLayers
+	return nil
+}
+
+// Normalize sets the creation time and the history of the image to fixed values, and clears the docker version and
+// container of its config, so that saving the same layers and config gives the same image.
+func (i *Image) Normalize() error {
+	image, err := mutate.CreatedAt(i.V1Image, v1.Time{Time: imgutil.NormalizedDateTime})
+	if err != nil {
+		return errors.Wrap(err, "set creation time")
+	}
+
+	cfg, err := image.ConfigFile()
+	if err != nil {
+		return errors.Wrap(err, "get image config")
+	}
+	cfg = cfg.DeepCopy()
+
+	layers, err := image.Layers()
+	if err != nil {
+		return errors.Wrap(err, "get image layers")
+	}
+	cfg.History = make([]v1.History, len(layers))
+	for i := range cfg.History {
+		cfg.History[i] = v1.History{
+			Created: v1.Time{Time: imgutil.NormalizedDateTime},
+		}
+	}
+
+	cfg.DockerVersion = ""
+	cfg.Container = ""
+	image, err = mutate.ConfigFile(image, cfg)
+	if err != nil {
+		return errors.Wrap(err, "zeroing history")
+	}
+	i.V1Image = image
+	return nil
+}
+
+// LayerCount returns the number of layers of image.
+func LayerCount(image v1.Image) (int, error) {
+	layers, err := image.Layers()
+	if err != nil {
+		return 0, err
+	}
+	return len(layers), nil
+}
+
+// subImage is the image made of the layers of img up to and including topDiffID, which is all that mutate.Rebase
+// reads of the old base.
+type subImage struct {
+	img       v1.Image
+	topDiffID string
+}
+
+func (si *subImage) Layers() ([]v1.Layer, error) {
+	all, err := si.img.Layers()
+	if err != nil {
+		return nil, err
+	}
+	for i, l := range all {
+		d, err := l.DiffID()
+		if err != nil {
+			return nil, err
+		}
+		if d.String() == si.topDiffID {
+			return all[0 : i+1], nil
+		}
+	}
+	return nil, imgutil.Errorf(imgutil.ErrLayerNotFound, "could not find base layer '%s' in image", si.topDiffID)
+}
+func (si *subImage) BlobSet() (map[v1.Hash]struct{}, error)  { panic("Not Implemented") }
+func (si *subImage) MediaType() (types.MediaType, error)     { panic("Not Implemented") }
+func (si *subImage) ConfigName() (v1.Hash, error)            { panic("Not Implemented") }
+func (si *subImage) ConfigFile() (*v1.ConfigFile, error)     { panic("Not Implemented") }
+func (si *subImage) RawConfigFile() ([]byte, error)          { panic("Not Implemented") }
+func (si *subImage) Digest() (v1.Hash, error)                { panic("Not Implemented") }
+func (si *subImage) Manifest() (*v1.Manifest, error)         { panic("Not Implemented") }
+func (si *subImage) RawManifest() ([]byte, error)            { panic("Not Implemented") }
+func (si *subImage) LayerByDigest(v1.Hash) (v1.Layer, error) { panic("Not Implemented") }
+func (si *subImage) LayerByDiffID(v1.Hash) (v1.Layer, error) { panic("Not Implemented") }
+func (si *subImage) Size() (int64, error)                    { panic("Not Implemented") }
+
+// withoutLayer rebuilds image from its config and all of its layers except the one at idx.
+func withoutLayer(image v1.Image, layers []v1.Layer, idx int) (v1.Image, error) {
+	mediaType, err := image.MediaType()
+	if err != nil {
+		return nil, err
+	}
+	configFile, err := image.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+
+	// history is only carried over when it maps one-to-one onto layers
+	history := configFile.History
+	if len(history) != len(layers) {
+		history = nil
+	}
+
+	configFile = configFile.DeepCopy()
+	configFile.RootFS.DiffIDs = nil
+	configFile.History = nil
+	newImage, err := mutate.ConfigFile(mutate.MediaType(empty.Image, mediaType), configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var adds []mutate.Addendum
+	for n, layer := range layers {
+		if n == idx {
+			continue
+		}
+		add := mutate.Addendum{Layer: layer}
+		if history != nil {
+			add.History = history[n]
+		}
+		adds = append(adds, add)
+	}
+	return mutate.Append(newImage, adds...)
+}
@@ -0,0 +1,82 @@
+package layer
+
+import (
+	"io"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/pkg/errors"
+
+	"github.com/buildpacks/imgutil"
+)
+
+// FromImage returns the layer of img with the given diff id. Its contents are read with img.GetLayer, and only
+// compressed when its compressed contents, digest or size are needed.
+func FromImage(img imgutil.Image, diffID string) (v1.Layer, error) {
+	hash, err := v1.NewHash(diffID)
+	if err != nil {
+		return nil, err
+	}
+	return partial.UncompressedToLayer(&imageLayer{img: img, diffID: hash})
+}
+
+// V1Image returns an image with the layers and platform of img, so that an image of any backend can be used where a
+// v1.Image is needed, e.g. as the new base image of a rebase.
+func V1Image(img imgutil.Image) (v1.Image, error) {
+	diffIDs, err := img.DiffIDs()
+	if err != nil {
+		return nil, errors.Wrapf(err, "get layers of image '%s'", img.Name())
+	}
+
+	var addenda []mutate.Addendum
+	for _, diffID := range diffIDs {
+		layer, err := FromImage(img, diffID)
+		if err != nil {
+			return nil, err
+		}
+		addenda = append(addenda, mutate.Addendum{
+			Layer:   layer,
+			History: v1.History{Created: v1.Time{Time: imgutil.NormalizedDateTime}},
+		})
+	}
+	image, err := mutate.Append(empty.Image, addenda...)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := image.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	cfg = cfg.DeepCopy()
+	if cfg.OS, err = img.OS(); err != nil {
+		return nil, err
+	}
+	if cfg.OSVersion, err = img.OSVersion(); err != nil {
+		return nil, err
+	}
+	if cfg.Architecture, err = img.Architecture(); err != nil {
+		return nil, err
+	}
+	return mutate.ConfigFile(image, cfg)
+}
+
+type imageLayer struct {
+	img    imgutil.Image
+	diffID v1.Hash
+}
+
+func (l *imageLayer) DiffID() (v1.Hash, error) {
+	return l.diffID, nil
+}
+
+func (l *imageLayer) Uncompressed() (io.ReadCloser, error) {
+	return l.img.GetLayer(l.diffID.String())
+}
+
+func (l *imageLayer) MediaType() (types.MediaType, error) {
+	return types.DockerLayer, nil
+}
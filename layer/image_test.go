@@ -0,0 +1,85 @@
+package layer_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil/fakes"
+	"github.com/buildpacks/imgutil/layer"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestImage(t *testing.T) {
+	spec.Run(t, "image", testImage, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testImage(t *testing.T, when spec.G, it spec.S) {
+	var (
+		img        *fakes.Image
+		layer1Path string
+		layer2Path string
+	)
+
+	it.Before(func() {
+		var err error
+		layer1Path, err = h.CreateSingleFileLayerTar("/layer-1.txt", "layer-1", "linux")
+		h.AssertNil(t, err)
+		layer2Path, err = h.CreateSingleFileLayerTar("/layer-2.txt", "layer-2", "linux")
+		h.AssertNil(t, err)
+
+		img = fakes.NewImage("some-image", "", nil)
+		img.SetPlatform("windows", "10.0.17763.1040", "arm64")
+		h.AssertNil(t, img.AddLayer(layer1Path))
+		h.AssertNil(t, img.AddLayer(layer2Path))
+	})
+
+	it.After(func() {
+		os.Remove(layer1Path)
+		os.Remove(layer2Path)
+	})
+
+	when("#FromImage", func() {
+		it("reads the layer from the image", func() {
+			l, err := layer.FromImage(img, h.FileDiffID(t, layer2Path))
+			h.AssertNil(t, err)
+
+			rc, err := l.Uncompressed()
+			h.AssertNil(t, err)
+			defer rc.Close()
+			contents, err := ioutil.ReadAll(rc)
+			h.AssertNil(t, err)
+
+			expected, err := ioutil.ReadFile(layer2Path)
+			h.AssertNil(t, err)
+			h.AssertEq(t, contents, expected)
+		})
+
+		it("returns an error for an invalid diff id", func() {
+			_, err := layer.FromImage(img, "some-diff-id")
+			h.AssertError(t, err, "some-diff-id")
+		})
+	})
+
+	when("#V1Image", func() {
+		it("has the layers and platform of the image", func() {
+			v1Img, err := layer.V1Image(img)
+			h.AssertNil(t, err)
+
+			cfg, err := v1Img.ConfigFile()
+			h.AssertNil(t, err)
+			h.AssertEq(t, cfg.OS, "windows")
+			h.AssertEq(t, cfg.OSVersion, "10.0.17763.1040")
+			h.AssertEq(t, cfg.Architecture, "arm64")
+
+			var diffIDs []string
+			for _, diffID := range cfg.RootFS.DiffIDs {
+				diffIDs = append(diffIDs, diffID.String())
+			}
+			h.AssertEq(t, diffIDs, []string{h.FileDiffID(t, layer1Path), h.FileDiffID(t, layer2Path)})
+		})
+	})
+}
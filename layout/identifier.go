@@ -0,0 +1,13 @@
+package layout
+
+import (
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+type DigestIdentifier struct {
+	Digest v1.Hash
+}
+
+func (d DigestIdentifier) String() string {
+	return d.Digest.String()
+}
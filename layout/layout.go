@@ -0,0 +1,191 @@
+package layout
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/pkg/errors"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/internal/v1image"
+)
+
+type Image struct {
+	v1image.Image
+	path string
+}
+
+type ImageOption func(*Image) (*Image, error)
+
+func WithPreviousImage(path string) ImageOption {
+	return func(i *Image) (*Image, error) {
+		prevImage, err := newV1Image(path)
+		if err != nil {
+			return nil, err
+		}
+
+		prevLayers, err := prevImage.Layers()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get layers for previous image at path '%s'", path)
+		}
+
+		i.PrevLayers = prevLayers
+		return i, nil
+	}
+}
+
+func FromBaseImage(path string) ImageOption {
+	return func(i *Image) (*Image, error) {
+		var err error
+
+		i.V1Image, err = newV1Image(path)
+		if err != nil {
+			return nil, err
+		}
+		return i, nil
+	}
+}
+
+// NewImage returns an image that is saved to an OCI image layout at path.
+func NewImage(path string, ops ...ImageOption) (imgutil.Image, error) {
+	image, err := v1image.Empty(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	li := &Image{
+		Image: v1image.Image{V1Image: image},
+		path:  path,
+	}
+	li.Describe = func() string {
+		return fmt.Sprintf("image at path '%s'", li.path)
+	}
+
+	for _, op := range ops {
+		li, err = op(li)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if li.BaseLayers, err = v1image.LayerCount(li.V1Image); err != nil {
+		return nil, err
+	}
+
+	return li, nil
+}
+
+func newV1Image(path string) (v1.Image, error) {
+	if !isLayout(path) {
+		return v1image.Empty(nil)
+	}
+
+	index, err := layout.ImageIndexFromPath(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read image index at path '%s'", path)
+	}
+
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, errors.Wrapf(err, "read index manifest at path '%s'", path)
+	}
+
+	for _, desc := range manifest.Manifests {
+		switch desc.MediaType {
+		case types.OCIManifestSchema1, types.DockerManifestSchema2:
+			return index.Image(desc.Digest)
+		}
+	}
+
+	return nil, imgutil.Errorf(imgutil.ErrInvalidImage, "index at path '%s' has no image manifest", path)
+}
+
+func isLayout(path string) bool {
+	_, err := os.Stat(filepath.Join(path, "index.json"))
+	return err == nil
+}
+
+func (i *Image) Rename(name string) {
+	i.path = name
+}
+
+func (i *Image) Name() string {
+	return i.path
+}
+
+func (i *Image) Found() bool {
+	return isLayout(i.path)
+}
+
+func (i *Image) Identifier() (imgutil.Identifier, error) {
+	hash, err := i.V1Image.Digest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get digest for image at path '%s': %s", i.path, err)
+	}
+
+	return DigestIdentifier{
+		Digest: hash,
+	}, nil
+}
+
+func (i *Image) Rebase(baseTopLayer string, newBase imgutil.Image, ops ...imgutil.RebaseOption) error {
+	return i.RebaseContext(context.Background(), baseTopLayer, newBase, ops...)
+}
+
+func (i *Image) RebaseContext(ctx context.Context, baseTopLayer string, newBase imgutil.Image, ops ...imgutil.RebaseOption) error {
+	return i.RebaseOnto(ctx, i, baseTopLayer, newBase, ops...)
+}
+
+func (i *Image) Save(additionalNames ...string) error {
+	return i.SaveContext(context.Background(), additionalNames...)
+}
+
+func (i *Image) SaveContext(ctx context.Context, additionalNames ...string) error {
+	allNames := append([]string{i.path}, additionalNames...)
+
+	if err := i.Normalize(); err != nil {
+		return err
+	}
+
+	var diagnostics []imgutil.SaveDiagnostic
+	for _, n := range allNames {
+		if err := ctx.Err(); err != nil {
+			diagnostics = append(diagnostics, imgutil.SaveDiagnostic{ImageName: n, Cause: err})
+			continue
+		}
+		if err := i.doSave(n); err != nil {
+			diagnostics = append(diagnostics, imgutil.SaveDiagnostic{ImageName: n, Cause: err})
+		}
+	}
+	if len(diagnostics) > 0 {
+		return imgutil.SaveError{Errors: diagnostics}
+	}
+
+	return nil
+}
+
+func (i *Image) doSave(path string) error {
+	// the index replaces any image previously saved at path, blobs are kept so they may be shared. It is written
+	// after the blobs, so the previous image is left in place if writing them fails.
+	_, err := layout.Write(path, mutate.AppendManifests(empty.Index, mutate.IndexAddendum{Add: i.V1Image}))
+	return err
+}
+
+func (i *Image) Delete() error {
+	if !isLayout(i.path) {
+		return nil
+	}
+	for _, name := range []string{"index.json", "oci-layout", "blobs"} {
+		if err := os.RemoveAll(filepath.Join(i.path, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,476 @@
+package layout_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	ggcrlayout "github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/imgutiltest"
+	"github.com/buildpacks/imgutil/layout"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestLayout(t *testing.T) {
+	rand.Seed(time.Now().UTC().UnixNano())
+
+	spec.Run(t, "Image", testImage, spec.Parallel(), spec.Report(report.Terminal{}))
+
+	tmpDir, err := ioutil.TempDir("", "imgutil.layout.conformance.")
+	h.AssertNil(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	imgutiltest.Run(t, imgutiltest.Factory{
+		NewName: func(t *testing.T) string {
+			return newImagePath(tmpDir)
+		},
+		NewImage: func(t *testing.T, path, basePath, prevPath string) imgutil.Image {
+			var ops []layout.ImageOption
+			if basePath != "" {
+				ops = append(ops, layout.FromBaseImage(basePath))
+			}
+			if prevPath != "" {
+				ops = append(ops, layout.WithPreviousImage(prevPath))
+			}
+			img, err := layout.NewImage(path, ops...)
+			h.AssertNil(t, err)
+			return img
+		},
+	})
+}
+
+func testImage(t *testing.T, when spec.G, it spec.S) {
+	var (
+		tmpDir    string
+		imagePath string
+	)
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "imgutil.layout.test.")
+		h.AssertNil(t, err)
+
+		imagePath = newImagePath(tmpDir)
+	})
+
+	it.After(func() {
+		h.AssertNil(t, os.RemoveAll(tmpDir))
+	})
+
+	it("implements imgutil.Image", func() {
+		var _ imgutil.Image = &layout.Image{}
+	})
+
+	when("#NewImage", func() {
+		when("no base image is given", func() {
+			it("sets sensible defaults for all required fields", func() {
+				img, err := layout.NewImage(imagePath)
+				h.AssertNil(t, err)
+				h.AssertNil(t, img.Save())
+
+				os, err := img.OS()
+				h.AssertNil(t, err)
+				h.AssertEq(t, os, "linux")
+
+				osVersion, err := img.OSVersion()
+				h.AssertNil(t, err)
+				h.AssertEq(t, osVersion, "")
+
+				arch, err := img.Architecture()
+				h.AssertNil(t, err)
+				h.AssertEq(t, arch, "amd64")
+			})
+		})
+
+		when("#FromBaseImage", func() {
+			when("base image exists", func() {
+				it("sets the initial state from the base image", func() {
+					basePath := newImagePath(tmpDir)
+					layerPath, err := h.CreateSingleFileLayerTar("/base.txt", "base", "linux")
+					h.AssertNil(t, err)
+					defer os.Remove(layerPath)
+
+					baseImage, err := layout.NewImage(basePath)
+					h.AssertNil(t, err)
+					h.AssertNil(t, baseImage.SetLabel("some.label", "some.value"))
+					h.AssertNil(t, baseImage.SetEnv("MY_VAR", "my_val"))
+					h.AssertNil(t, baseImage.AddLayer(layerPath))
+					h.AssertNil(t, baseImage.Save())
+
+					img, err := layout.NewImage(imagePath, layout.FromBaseImage(basePath))
+					h.AssertNil(t, err)
+
+					label, err := img.Label("some.label")
+					h.AssertNil(t, err)
+					h.AssertEq(t, label, "some.value")
+
+					val, err := img.Env("MY_VAR")
+					h.AssertNil(t, err)
+					h.AssertEq(t, val, "my_val")
+
+					topLayer, err := img.TopLayer()
+					h.AssertNil(t, err)
+					h.AssertEq(t, topLayer, h.FileDiffID(t, layerPath))
+				})
+			})
+
+			when("base image does not exist", func() {
+				it("doesn't error", func() {
+					_, err := layout.NewImage(imagePath, layout.FromBaseImage(filepath.Join(tmpDir, "some-bad-path")))
+					h.AssertNil(t, err)
+				})
+			})
+
+			when("base image index has no image", func() {
+				it("returns an error", func() {
+					_, err := ggcrlayout.Write(imagePath, empty.Index)
+					h.AssertNil(t, err)
+
+					_, err = layout.NewImage(imagePath, layout.FromBaseImage(imagePath))
+					h.AssertError(t, err, "has no image manifest")
+					h.AssertEq(t, errors.Is(err, imgutil.ErrInvalidImage), true)
+				})
+			})
+		})
+
+		when("#WithPreviousImage", func() {
+			when("previous image does not exist", func() {
+				it("doesn't error", func() {
+					_, err := layout.NewImage(imagePath, layout.WithPreviousImage(filepath.Join(tmpDir, "some-bad-path")))
+					h.AssertNil(t, err)
+				})
+			})
+		})
+	})
+
+	when("config getters", func() {
+		it("returns the values that were set", func() {
+			img, err := layout.NewImage(imagePath)
+			h.AssertNil(t, err)
+
+			h.AssertNil(t, img.SetLabel("some.label", "some.value"))
+			h.AssertNil(t, img.SetEnv("SOME_KEY", "some=value"))
+			h.AssertNil(t, img.SetEntrypoint("some", "entrypoint"))
+			h.AssertNil(t, img.SetCmd("some", "cmd"))
+			h.AssertNil(t, img.SetWorkingDir("/some/dir"))
+			h.AssertNil(t, img.Save())
+
+			saved, err := layout.NewImage(newImagePath(tmpDir), layout.FromBaseImage(imagePath))
+			h.AssertNil(t, err)
+
+			labels, err := saved.Labels()
+			h.AssertNil(t, err)
+			h.AssertEq(t, labels, map[string]string{"some.label": "some.value"})
+
+			envVars, err := saved.EnvVars()
+			h.AssertNil(t, err)
+			h.AssertEq(t, envVars["SOME_KEY"], "some=value")
+
+			entrypoint, err := saved.Entrypoint()
+			h.AssertNil(t, err)
+			h.AssertEq(t, entrypoint, []string{"some", "entrypoint"})
+
+			cmd, err := saved.Cmd()
+			h.AssertNil(t, err)
+			h.AssertEq(t, cmd, []string{"some", "cmd"})
+
+			workingDir, err := saved.WorkingDir()
+			h.AssertNil(t, err)
+			h.AssertEq(t, workingDir, "/some/dir")
+		})
+
+		it("returns zero values for an empty image", func() {
+			img, err := layout.NewImage(imagePath)
+			h.AssertNil(t, err)
+
+			user, err := img.User()
+			h.AssertNil(t, err)
+			h.AssertEq(t, user, "")
+
+			ports, err := img.ExposedPorts()
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(ports), 0)
+
+			volumes, err := img.Volumes()
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(volumes), 0)
+
+			stopSignal, err := img.StopSignal()
+			h.AssertNil(t, err)
+			h.AssertEq(t, stopSignal, "")
+		})
+
+		it("returns copies that don't modify the image", func() {
+			img, err := layout.NewImage(imagePath)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.SetLabel("some.label", "some.value"))
+
+			labels, err := img.Labels()
+			h.AssertNil(t, err)
+			labels["some.label"] = "other.value"
+
+			val, err := img.Label("some.label")
+			h.AssertNil(t, err)
+			h.AssertEq(t, val, "some.value")
+		})
+	})
+
+	when("config setters", func() {
+		it("sets the config values", func() {
+			img, err := layout.NewImage(imagePath)
+			h.AssertNil(t, err)
+
+			h.AssertNil(t, img.SetUser("some-user"))
+			h.AssertNil(t, img.SetExposedPorts(map[string]struct{}{"8080/tcp": {}}))
+			h.AssertNil(t, img.SetVolumes(map[string]struct{}{"/some/volume": {}}))
+			h.AssertNil(t, img.SetHealthcheck(imgutil.HealthConfig{
+				Test:     []string{"CMD", "some-check"},
+				Interval: 30 * time.Second,
+				Retries:  3,
+			}))
+			h.AssertNil(t, img.SetStopSignal("SIGKILL"))
+			h.AssertNil(t, img.SetShell("/bin/bash", "-c"))
+			h.AssertNil(t, img.SetOnBuild("RUN some-trigger"))
+			h.AssertNil(t, img.Save())
+
+			cfg := configFile(t, imagePath)
+			h.AssertEq(t, cfg.Config.User, "some-user")
+			h.AssertEq(t, cfg.Config.ExposedPorts, map[string]struct{}{"8080/tcp": {}})
+			h.AssertEq(t, cfg.Config.Volumes, map[string]struct{}{"/some/volume": {}})
+			h.AssertEq(t, cfg.Config.Healthcheck, &v1.HealthConfig{
+				Test:     []string{"CMD", "some-check"},
+				Interval: 30 * time.Second,
+				Retries:  3,
+			})
+			h.AssertEq(t, cfg.Config.StopSignal, "SIGKILL")
+			h.AssertEq(t, cfg.Config.Shell, []string{"/bin/bash", "-c"})
+			h.AssertEq(t, cfg.Config.OnBuild, []string{"RUN some-trigger"})
+		})
+
+		it("replaces existing ports and volumes", func() {
+			img, err := layout.NewImage(imagePath)
+			h.AssertNil(t, err)
+
+			h.AssertNil(t, img.SetExposedPorts(map[string]struct{}{"8080/tcp": {}}))
+			h.AssertNil(t, img.SetExposedPorts(map[string]struct{}{"9090/udp": {}}))
+			h.AssertNil(t, img.SetVolumes(map[string]struct{}{"/some/volume": {}}))
+			h.AssertNil(t, img.SetVolumes(map[string]struct{}{"/other/volume": {}}))
+
+			ports, err := img.ExposedPorts()
+			h.AssertNil(t, err)
+			h.AssertEq(t, ports, map[string]struct{}{"9090/udp": {}})
+
+			volumes, err := img.Volumes()
+			h.AssertNil(t, err)
+			h.AssertEq(t, volumes, map[string]struct{}{"/other/volume": {}})
+		})
+	})
+
+	when("#RemoveLayer", func() {
+		var (
+			baseImagePath string
+			baseLayerSHA  string
+			layer1Path    string
+			layer2Path    string
+		)
+
+		it.Before(func() {
+			baseImagePath = newImagePath(tmpDir)
+			baseImage, err := layout.NewImage(baseImagePath)
+			h.AssertNil(t, err)
+
+			baseLayerPath, err := h.CreateSingleFileLayerTar("/base.txt", "base", "linux")
+			h.AssertNil(t, err)
+			defer os.Remove(baseLayerPath)
+			baseLayerSHA = h.FileDiffID(t, baseLayerPath)
+
+			h.AssertNil(t, baseImage.AddLayer(baseLayerPath))
+			h.AssertNil(t, baseImage.Save())
+
+			layer1Path, err = h.CreateSingleFileLayerTar("/layer-1.txt", "layer-1", "linux")
+			h.AssertNil(t, err)
+			layer2Path, err = h.CreateSingleFileLayerTar("/layer-2.txt", "layer-2", "linux")
+			h.AssertNil(t, err)
+		})
+
+		it.After(func() {
+			os.Remove(layer1Path)
+			os.Remove(layer2Path)
+		})
+
+		it("removes an added layer", func() {
+			img, err := layout.NewImage(imagePath, layout.FromBaseImage(baseImagePath))
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.AddLayer(layer1Path))
+			h.AssertNil(t, img.AddLayer(layer2Path))
+
+			h.AssertNil(t, img.RemoveLayer(h.FileDiffID(t, layer1Path)))
+			h.AssertNil(t, img.Save())
+
+			h.AssertEq(t, layerDiffIDs(t, imagePath), []string{baseLayerSHA, h.FileDiffID(t, layer2Path)})
+		})
+	})
+
+	when("#TopLayer", func() {
+		when("the image has no layers", func() {
+			it("returns an error", func() {
+				img, err := layout.NewImage(imagePath)
+				h.AssertNil(t, err)
+
+				_, err = img.TopLayer()
+				h.AssertError(t, err, "has no layers")
+			})
+		})
+	})
+
+	when("#GetLayer", func() {
+		it("returns the uncompressed layer contents", func() {
+			layerPath, err := h.CreateSingleFileLayerTar("/some-file.txt", "some-contents", "linux")
+			h.AssertNil(t, err)
+			defer os.Remove(layerPath)
+
+			img, err := layout.NewImage(imagePath)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.AddLayer(layerPath))
+
+			rc, err := img.GetLayer(h.FileDiffID(t, layerPath))
+			h.AssertNil(t, err)
+			defer rc.Close()
+
+			actual, err := ioutil.ReadAll(rc)
+			h.AssertNil(t, err)
+			expected, err := ioutil.ReadFile(layerPath)
+			h.AssertNil(t, err)
+			h.AssertEq(t, actual, expected)
+		})
+	})
+
+	when("#Save", func() {
+		it("writes an OCI image layout", func() {
+			img, err := layout.NewImage(imagePath)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.Save())
+
+			for _, name := range []string{"oci-layout", "index.json", "blobs"} {
+				_, err := os.Stat(filepath.Join(imagePath, name))
+				h.AssertNil(t, err)
+			}
+		})
+
+		it("zeroes all times and client specific fields", func() {
+			img, err := layout.NewImage(imagePath)
+			h.AssertNil(t, err)
+
+			layerPath, err := h.CreateSingleFileLayerTar("/new-layer.txt", "new-layer", "linux")
+			h.AssertNil(t, err)
+			defer os.Remove(layerPath)
+
+			h.AssertNil(t, img.AddLayer(layerPath))
+			h.AssertNil(t, img.Save())
+
+			createdAt, err := img.CreatedAt()
+			h.AssertNil(t, err)
+			h.AssertEq(t, createdAt, imgutil.NormalizedDateTime)
+		})
+
+		it("replaces a previously saved image", func() {
+			img, err := layout.NewImage(imagePath)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.Save())
+
+			h.AssertNil(t, img.SetLabel("mykey", "new-val"))
+			h.AssertNil(t, img.Save())
+
+			index, err := ggcrlayout.ImageIndexFromPath(imagePath)
+			h.AssertNil(t, err)
+			manifest, err := index.IndexManifest()
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(manifest.Manifests), 1)
+
+			identifier, err := img.Identifier()
+			h.AssertNil(t, err)
+			h.AssertEq(t, manifest.Manifests[0].Digest.String(), identifier.String())
+		})
+
+		it("keeps a previously saved image when writing the new one fails", func() {
+			img, err := layout.NewImage(imagePath)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.Save())
+			savedID, err := img.Identifier()
+			h.AssertNil(t, err)
+
+			layerPath, err := h.CreateSingleFileLayerTar("/new-layer.txt", "new-layer", "linux")
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.AddLayer(layerPath))
+			h.AssertNil(t, os.Remove(layerPath))
+			h.AssertNotEq(t, img.Save(), nil)
+
+			saved, err := layout.NewImage(imagePath, layout.FromBaseImage(imagePath))
+			h.AssertNil(t, err)
+			identifier, err := saved.Identifier()
+			h.AssertNil(t, err)
+			h.AssertEq(t, identifier.String(), savedID.String())
+		})
+
+	})
+
+	when("#Found", func() {
+		it("returns false when nothing was saved", func() {
+			img, err := layout.NewImage(imagePath)
+			h.AssertNil(t, err)
+			h.AssertEq(t, img.Found(), false)
+		})
+	})
+
+	when("#Delete", func() {
+		it("removes the image layout", func() {
+			img, err := layout.NewImage(imagePath)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.Save())
+			h.AssertEq(t, img.Found(), true)
+
+			h.AssertNil(t, img.Delete())
+			h.AssertEq(t, img.Found(), false)
+		})
+	})
+}
+
+func newImagePath(dir string) string {
+	return filepath.Join(dir, "layout-image-test-"+h.RandString(10))
+}
+
+func configFile(t *testing.T, path string) *v1.ConfigFile {
+	t.Helper()
+
+	index, err := ggcrlayout.ImageIndexFromPath(path)
+	h.AssertNil(t, err)
+	manifest, err := index.IndexManifest()
+	h.AssertNil(t, err)
+	img, err := index.Image(manifest.Manifests[0].Digest)
+	h.AssertNil(t, err)
+	cfg, err := img.ConfigFile()
+	h.AssertNil(t, err)
+	return cfg
+}
+
+func layerDiffIDs(t *testing.T, path string) []string {
+	t.Helper()
+
+	cfg := configFile(t, path)
+
+	var diffIDs []string
+	for _, diffID := range cfg.RootFS.DiffIDs {
+		diffIDs = append(diffIDs, diffID.String())
+	}
+	return diffIDs
+}
@@ -0,0 +1,245 @@
+package local
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/docker/docker/client"
+	"github.com/pkg/errors"
+
+	"github.com/buildpacks/imgutil"
+)
+
+// FileSystemLocalImage is an image read from the daemon with `docker save`. The archive is spooled to a single file
+// that is indexed as it is written, so that layers can be read from it without extracting them.
+type FileSystemLocalImage struct {
+	archive   string
+	entries   map[string]archiveEntry
+	layers    []string
+	diffIDs   []string
+	layersMap map[string]string
+}
+
+// archiveEntry is the position of a regular file's contents in the archive.
+type archiveEntry struct {
+	offset int64
+	size   int64
+}
+
+// downloadImage spools the archive of imageName to a file in scratchDir, which is removed unless the image is returned.
+func downloadImage(ctx context.Context, docker client.CommonAPIClient, imageName, scratchDir string, progress imgutil.ProgressFunc) (_ *FileSystemLocalImage, err error) {
+	imageReader, err := docker.ImageSave(ctx, []string{imageName})
+	if err != nil {
+		return nil, daemonError(err, "save image '%s' from daemon", imageName)
+	}
+	defer ensureReaderClosed(imageReader)
+
+	spool, err := ioutil.TempFile(scratchDir, "imgutil.local.image.")
+	if err != nil {
+		return nil, errors.Wrap(err, "local reuse-layer create temp file")
+	}
+	defer func() {
+		spool.Close()
+		if err != nil {
+			os.Remove(spool.Name())
+		}
+	}()
+
+	fsimg := &FileSystemLocalImage{archive: spool.Name()}
+	if fsimg.entries, err = indexArchive(io.TeeReader(imageReader, spool), progress); err != nil {
+		return nil, err
+	}
+	if err := spool.Close(); err != nil {
+		return nil, err
+	}
+
+	var manifest []struct {
+		Config string
+		Layers []string
+	}
+	if err := fsimg.decodeEntry("manifest.json", &manifest); err != nil {
+		return nil, err
+	}
+
+	if len(manifest) != 1 {
+		return nil, imgutil.Errorf(imgutil.ErrInvalidImage, "manifest.json had unexpected number of entries: %d", len(manifest))
+	}
+
+	var details struct {
+		RootFS struct {
+			DiffIDs []string `json:"diff_ids"`
+		} `json:"rootfs"`
+	}
+	if err := fsimg.decodeEntry(manifest[0].Config, &details); err != nil {
+		return nil, err
+	}
+
+	if len(manifest[0].Layers) != len(details.RootFS.DiffIDs) {
+		return nil, imgutil.Errorf(imgutil.ErrInvalidImage, "layers and diff IDs do not match, there are %d layers and %d diffIDs", len(manifest[0].Layers), len(details.RootFS.DiffIDs))
+	}
+
+	for _, layer := range manifest[0].Layers {
+		if _, err := fsimg.entry(layer); err != nil {
+			return nil, err
+		}
+	}
+
+	fsimg.layers = manifest[0].Layers
+	fsimg.diffIDs = details.RootFS.DiffIDs
+	fsimg.layersMap = make(map[string]string, len(manifest[0].Layers))
+	for i, diffID := range details.RootFS.DiffIDs {
+		fsimg.layersMap[diffID] = manifest[0].Layers[i]
+	}
+
+	return fsimg, nil
+}
+
+// indexArchive reads the archive from r to its end, returning the position of each regular file in it. Hard links and
+// symlinks are indexed as the file they point to, and special files are skipped. Nothing is extracted, but names and
+// link targets that escape the root of the archive are rejected so that they can't alias other files. The reading of
+// each regular file is reported to progress when it is set.
+func indexArchive(r io.Reader, progress imgutil.ProgressFunc) (map[string]archiveEntry, error) {
+	counter := &countingReader{r: r}
+	tr := tar.NewReader(counter)
+
+	entries := map[string]archiveEntry{}
+	links := map[string]string{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name, err := archivePath(hdr.Name)
+		if err != nil {
+			return nil, err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir, tar.TypeChar, tar.TypeBlock, tar.TypeFifo, tar.TypeXGlobalHeader:
+		case tar.TypeReg, tar.TypeRegA:
+			entries[name] = archiveEntry{offset: counter.n, size: hdr.Size}
+			var contents io.Reader = tr
+			if progress != nil {
+				contents = imgutil.NewProgressReader(tr, hdr.Name, hdr.Size, progress)
+			}
+			if _, err := io.Copy(ioutil.Discard, contents); err != nil {
+				return nil, err
+			}
+		case tar.TypeLink:
+			// hard links name a file earlier in the archive, relative to its root
+			target, err := archivePath(hdr.Linkname)
+			if err != nil {
+				return nil, err
+			}
+			entry, ok := entries[target]
+			if !ok {
+				return nil, imgutil.Errorf(imgutil.ErrInvalidImage, "hard link '%s' points to '%s', which is not a file in the archive", hdr.Name, hdr.Linkname)
+			}
+			entries[name] = entry
+		case tar.TypeSymlink:
+			if path.IsAbs(hdr.Linkname) {
+				return nil, imgutil.Errorf(imgutil.ErrInvalidImage, "symlink '%s' has absolute target '%s'", hdr.Name, hdr.Linkname)
+			}
+			target, err := archivePath(path.Join(path.Dir(name), hdr.Linkname))
+			if err != nil {
+				return nil, err
+			}
+			links[name] = target
+		default:
+			return nil, imgutil.Errorf(imgutil.ErrInvalidImage, "unknown file type in tar %d", hdr.Typeflag)
+		}
+	}
+
+	for name, target := range links {
+		// follow chains of links, giving up on cycles
+		for i := 0; i < len(links); i++ {
+			next, ok := links[target]
+			if !ok {
+				break
+			}
+			target = next
+		}
+		if entry, ok := entries[target]; ok {
+			entries[name] = entry
+		}
+	}
+
+	// drain the end of archive marker and any padding, so that everything the daemon sent is spooled
+	if _, err := io.Copy(ioutil.Discard, counter); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// archivePath normalizes a name in the archive, which may be written with a leading "./" or "/". It is an error for
+// the name to escape the root of the archive.
+func archivePath(name string) (string, error) {
+	cleaned := path.Clean(strings.TrimLeft(name, "/"))
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", imgutil.Errorf(imgutil.ErrInvalidImage, "path '%s' escapes the archive", name)
+	}
+	return cleaned, nil
+}
+
+func (fsimg *FileSystemLocalImage) entry(name string) (archiveEntry, error) {
+	cleaned, err := archivePath(name)
+	if err != nil {
+		return archiveEntry{}, err
+	}
+	entry, ok := fsimg.entries[cleaned]
+	if !ok {
+		return archiveEntry{}, imgutil.Errorf(imgutil.ErrInvalidImage, "archive has no file '%s'", name)
+	}
+	return entry, nil
+}
+
+// openEntry returns a reader of the contents of the regular file name in the archive, and its size.
+func (fsimg *FileSystemLocalImage) openEntry(name string) (io.ReadCloser, int64, error) {
+	entry, err := fsimg.entry(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	f, err := os.Open(fsimg.archive)
+	if err != nil {
+		return nil, 0, err
+	}
+	return &entryReader{SectionReader: io.NewSectionReader(f, entry.offset, entry.size), file: f}, entry.size, nil
+}
+
+func (fsimg *FileSystemLocalImage) decodeEntry(name string, v interface{}) error {
+	rc, _, err := fsimg.openEntry(name)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return json.NewDecoder(rc).Decode(v)
+}
+
+type entryReader struct {
+	*io.SectionReader
+	file *os.File
+}
+
+func (r *entryReader) Close() error {
+	return r.file.Close()
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,213 @@
+package local_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/local"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestArchive(t *testing.T) {
+	rand.Seed(time.Now().UTC().UnixNano())
+
+	spec.Run(t, "Archive", testArchive, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+// archiveClient is a daemon that has a single image, whose `docker save` archive is archive.
+type archiveClient struct {
+	client.CommonAPIClient
+	archive []byte
+}
+
+func (c *archiveClient) Info(ctx context.Context) (types.Info, error) {
+	return types.Info{OSType: "linux"}, nil
+}
+
+func (c *archiveClient) ImageInspectWithRaw(ctx context.Context, image string) (types.ImageInspect, []byte, error) {
+	return types.ImageInspect{Os: "linux", Config: &container.Config{}}, nil, nil
+}
+
+func (c *archiveClient) ImageSave(ctx context.Context, images []string) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(c.archive)), nil
+}
+
+func testArchive(t *testing.T, when spec.G, it spec.S) {
+	const layerContents = "some-layer-contents"
+
+	var (
+		scratchDir string
+		diffID     string
+	)
+
+	it.Before(func() {
+		var err error
+		scratchDir, err = ioutil.TempDir("", "imgutil.local.archive.")
+		h.AssertNil(t, err)
+
+		sum := sha256.Sum256([]byte(layerContents))
+		diffID = "sha256:" + hex.EncodeToString(sum[:])
+	})
+
+	it.After(func() {
+		h.AssertNil(t, os.RemoveAll(scratchDir))
+	})
+
+	// reuseLayer reuses the layer of a previous image saved as the given archive entries, which are followed by the
+	// image's config and manifest listing layerName.
+	reuseLayer := func(layerName string, headers ...*tar.Header) (imgutil.Image, error) {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for _, hdr := range headers {
+			h.AssertNil(t, tw.WriteHeader(hdr))
+			if hdr.Typeflag == tar.TypeReg {
+				_, err := tw.Write([]byte(layerContents))
+				h.AssertNil(t, err)
+			}
+		}
+		writeJSON(t, tw, "config.json", map[string]interface{}{
+			"rootfs": map[string]interface{}{"diff_ids": []string{diffID}},
+		})
+		writeJSON(t, tw, "manifest.json", []map[string]interface{}{
+			{"Config": "config.json", "Layers": []string{layerName}},
+		})
+		h.AssertNil(t, tw.Close())
+
+		img, err := local.NewImage("some-image", &archiveClient{archive: buf.Bytes()},
+			local.WithPreviousImage("some-previous-image"),
+			local.WithScratchDir(scratchDir),
+		)
+		h.AssertNil(t, err)
+		return img, img.ReuseLayer(diffID)
+	}
+
+	file := func(name string) *tar.Header {
+		return &tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(layerContents))}
+	}
+
+	assertLayer := func(img imgutil.Image) {
+		rc, err := img.GetLayer(diffID)
+		h.AssertNil(t, err)
+		defer rc.Close()
+		contents, err := ioutil.ReadAll(rc)
+		h.AssertNil(t, err)
+		h.AssertEq(t, string(contents), layerContents)
+	}
+
+	assertInvalid := func(err error, expected string) {
+		h.AssertError(t, err, expected)
+		h.AssertEq(t, errors.Is(err, imgutil.ErrInvalidImage), true)
+
+		// the spooled archive is removed when it can't be read
+		entries, err := ioutil.ReadDir(scratchDir)
+		h.AssertNil(t, err)
+		h.AssertEq(t, len(entries), 0)
+	}
+
+	it("reads layers without extracting them", func() {
+		img, err := reuseLayer("abc/layer.tar",
+			&tar.Header{Name: "abc/", Typeflag: tar.TypeDir, Mode: 0755},
+			file("./abc/layer.tar"),
+		)
+		h.AssertNil(t, err)
+		assertLayer(img)
+
+		h.AssertNil(t, img.Cleanup())
+		entries, err := ioutil.ReadDir(scratchDir)
+		h.AssertNil(t, err)
+		h.AssertEq(t, len(entries), 0)
+	})
+
+	it("follows symlinks within the archive", func() {
+		img, err := reuseLayer("def/layer.tar",
+			file("abc/layer.tar"),
+			&tar.Header{Name: "def/layer.tar", Typeflag: tar.TypeSymlink, Linkname: "../abc/layer.tar"},
+		)
+		h.AssertNil(t, err)
+		assertLayer(img)
+	})
+
+	it("follows hard links", func() {
+		img, err := reuseLayer("def/layer.tar",
+			file("abc/layer.tar"),
+			&tar.Header{Name: "def/layer.tar", Typeflag: tar.TypeLink, Linkname: "abc/layer.tar"},
+		)
+		h.AssertNil(t, err)
+		assertLayer(img)
+	})
+
+	it("skips special files", func() {
+		img, err := reuseLayer("abc/layer.tar",
+			&tar.Header{Name: "some-fifo", Typeflag: tar.TypeFifo, Mode: 0644},
+			&tar.Header{Name: "some-device", Typeflag: tar.TypeChar, Mode: 0644, Devmajor: 1, Devminor: 3},
+			file("abc/layer.tar"),
+		)
+		h.AssertNil(t, err)
+		assertLayer(img)
+	})
+
+	it("rejects paths that escape the archive", func() {
+		_, err := reuseLayer("abc/layer.tar",
+			file("abc/layer.tar"),
+			file("../../etc/some-file"),
+		)
+		assertInvalid(err, "path '../../etc/some-file' escapes the archive")
+	})
+
+	it("rejects symlinks that escape the archive", func() {
+		_, err := reuseLayer("abc/layer.tar",
+			file("abc/layer.tar"),
+			&tar.Header{Name: "def/layer.tar", Typeflag: tar.TypeSymlink, Linkname: "../../etc/passwd"},
+		)
+		assertInvalid(err, "escapes the archive")
+	})
+
+	it("rejects symlinks with absolute targets", func() {
+		_, err := reuseLayer("abc/layer.tar",
+			file("abc/layer.tar"),
+			&tar.Header{Name: "def/layer.tar", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"},
+		)
+		assertInvalid(err, "symlink 'def/layer.tar' has absolute target '/etc/passwd'")
+	})
+
+	it("rejects hard links to files that aren't in the archive", func() {
+		_, err := reuseLayer("abc/layer.tar",
+			file("abc/layer.tar"),
+			&tar.Header{Name: "def/layer.tar", Typeflag: tar.TypeLink, Linkname: "some-missing-file"},
+		)
+		assertInvalid(err, "hard link 'def/layer.tar' points to 'some-missing-file', which is not a file in the archive")
+	})
+
+	it("rejects manifests naming layers outside the archive", func() {
+		_, err := reuseLayer("../layer.tar", file("abc/layer.tar"))
+		assertInvalid(err, "path '../layer.tar' escapes the archive")
+	})
+}
+
+func writeJSON(t *testing.T, tw *tar.Writer, name string, v interface{}) {
+	t.Helper()
+
+	b, err := json.Marshal(v)
+	h.AssertNil(t, err)
+	h.AssertNil(t, tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(b))}))
+	_, err = tw.Write(b)
+	h.AssertNil(t, err)
+}
@@ -10,7 +10,6 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
-	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -19,6 +18,7 @@ import (
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/docker/go-connections/nat"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/pkg/errors"
@@ -27,49 +27,140 @@ import (
 )
 
 type Image struct {
+	ctx           context.Context
 	repoName      string
 	docker        client.CommonAPIClient
 	inspect       types.ImageInspect
-	layerPaths    []string
+	layers        []layerSource
+	baseLayers    int
 	downloadOnce  *sync.Once
+	downloadErr   error
+	baseName      string
 	prevName      string
 	prevImage     *FileSystemLocalImage
 	easyAddLayers []string
+	progress      imgutil.ProgressFunc
+	scratchDir    string
 }
 
-type FileSystemLocalImage struct {
-	dir       string
-	layersMap map[string]string
+// layerSource is where Save reads an added layer from: a file, a layer in the archive of an image read from the
+// daemon, or the layer with diff id path of an image from another backend. It is empty for layers that the daemon
+// already has.
+type layerSource struct {
+	path       string
+	archive    *FileSystemLocalImage
+	image      imgutil.Image
+	scratchDir string
+}
+
+func (s layerSource) open() (io.ReadCloser, int64, error) {
+	if s.archive != nil {
+		return s.archive.openEntry(s.path)
+	}
+	if s.image != nil {
+		return spoolLayer(s.image, s.path, s.scratchDir)
+	}
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, 0, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, fi.Size(), nil
+}
+
+// key identifies the contents of the layer, so that each is sent to the daemon once.
+func (s layerSource) key() string {
+	if s.archive != nil {
+		return s.archive.archive + "!" + s.path
+	}
+	if s.image != nil {
+		return s.image.Name() + "@" + s.path
+	}
+	return s.path
+}
+
+// spoolLayer copies the layer of img to a temporary file in dir, because the size of a layer must be known before it
+// is sent to the daemon. The file is removed when the returned reader is closed.
+func spoolLayer(img imgutil.Image, diffID, dir string) (io.ReadCloser, int64, error) {
+	rc, err := img.GetLayer(diffID)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rc.Close()
+
+	f, err := ioutil.TempFile(dir, "imgutil.local.layer.")
+	if err != nil {
+		return nil, 0, err
+	}
+	spooled := &spooledLayer{File: f}
+	size, err := io.Copy(f, rc)
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		spooled.Close()
+		return nil, 0, err
+	}
+	return spooled, size, nil
+}
+
+type spooledLayer struct {
+	*os.File
+}
+
+func (l *spooledLayer) Close() error {
+	err := l.File.Close()
+	if removeErr := os.Remove(l.Name()); removeErr != nil && err == nil {
+		err = removeErr
+	}
+	return err
 }
 
 type ImageOption func(image *Image) (*Image, error)
 
 func WithPreviousImage(imageName string) ImageOption {
 	return func(i *Image) (*Image, error) {
-		if _, err := inspectOptionalImage(i.docker, imageName); err != nil {
-			return i, err
-		}
-
 		i.prevName = imageName
-
 		return i, nil
 	}
 }
 
 func FromBaseImage(imageName string) ImageOption {
 	return func(i *Image) (*Image, error) {
-		var (
-			err     error
-			inspect types.ImageInspect
-		)
+		i.baseName = imageName
+		return i, nil
+	}
+}
 
-		if inspect, err = inspectOptionalImage(i.docker, imageName); err != nil {
-			return i, err
-		}
+// WithContext sets the context used for daemon requests made by the image, unless a method is given its own context.
+func WithContext(ctx context.Context) ImageOption {
+	return func(i *Image) (*Image, error) {
+		i.ctx = ctx
+		return i, nil
+	}
+}
 
-		i.inspect = inspect
-		i.layerPaths = make([]string, len(i.inspect.RootFS.Layers))
+// WithProgress sets a function that is called as the previous image is read from the daemon and as Save loads the image into it.
+// Load progress is reported per layer as the daemon reports it.
+func WithProgress(fn imgutil.ProgressFunc) ImageOption {
+	return func(i *Image) (*Image, error) {
+		i.progress = fn
+		return i, nil
+	}
+}
 
+// WithScratchDir sets the directory that temporary files, such as the archive of the previous image, are created in.
+// It defaults to the system's temporary directory. The files are removed by Cleanup.
+func WithScratchDir(dir string) ImageOption {
+	return func(i *Image) (*Image, error) {
+		if _, err := os.Stat(dir); err != nil {
+			return nil, errors.Wrapf(err, "invalid scratch directory '%s'", dir)
+		}
+		i.scratchDir = dir
 		return i, nil
 	}
 }
@@ -77,16 +168,10 @@ func FromBaseImage(imageName string) ImageOption {
 func NewImage(repoName string, dockerClient client.CommonAPIClient, ops ...ImageOption) (imgutil.Image, error) {
 	var err error
 
-	inspect, err := defaultInspect(dockerClient)
-	if err != nil {
-		return nil, err
-	}
-
 	image := &Image{
+		ctx:          context.Background(),
 		docker:       dockerClient,
 		repoName:     repoName,
-		inspect:      inspect,
-		layerPaths:   make([]string, len(inspect.RootFS.Layers)),
 		downloadOnce: &sync.Once{},
 	}
 
@@ -97,6 +182,23 @@ func NewImage(repoName string, dockerClient client.CommonAPIClient, ops ...Image
 		}
 	}
 
+	if image.prevName != "" {
+		if _, err := inspectOptionalImage(image.ctx, dockerClient, image.prevName); err != nil {
+			return nil, err
+		}
+	}
+
+	if image.baseName != "" {
+		image.inspect, err = inspectOptionalImage(image.ctx, dockerClient, image.baseName)
+	} else {
+		image.inspect, err = defaultInspect(image.ctx, dockerClient)
+	}
+	if err != nil {
+		return nil, err
+	}
+	image.layers = make([]layerSource, len(image.inspect.RootFS.Layers))
+	image.baseLayers = len(image.inspect.RootFS.Layers)
+
 	return image, nil
 }
 
@@ -106,13 +208,63 @@ func (i *Image) Label(key string) (string, error) {
 }
 
 func (i *Image) Env(key string) (string, error) {
-	for _, envVar := range i.inspect.Config.Env {
-		parts := strings.Split(envVar, "=")
-		if parts[0] == key {
-			return parts[1], nil
-		}
+	val, _ := imgutil.GetEnv(i.inspect.Config.Env, i.inspect.Os, key)
+	return val, nil
+}
+
+func (i *Image) Labels() (map[string]string, error) {
+	labels := make(map[string]string, len(i.inspect.Config.Labels))
+	for k, v := range i.inspect.Config.Labels {
+		labels[k] = v
+	}
+	return labels, nil
+}
+
+func (i *Image) EnvVars() (map[string]string, error) {
+	return imgutil.EnvMap(i.inspect.Config.Env, i.inspect.Os), nil
+}
+
+func (i *Image) Entrypoint() ([]string, error) {
+	return copyStrings(i.inspect.Config.Entrypoint), nil
+}
+
+func (i *Image) Cmd() ([]string, error) {
+	return copyStrings(i.inspect.Config.Cmd), nil
+}
+
+func (i *Image) WorkingDir() (string, error) {
+	return i.inspect.Config.WorkingDir, nil
+}
+
+func (i *Image) User() (string, error) {
+	return i.inspect.Config.User, nil
+}
+
+func (i *Image) ExposedPorts() (map[string]struct{}, error) {
+	ports := make(map[string]struct{}, len(i.inspect.Config.ExposedPorts))
+	for port := range i.inspect.Config.ExposedPorts {
+		ports[string(port)] = struct{}{}
+	}
+	return ports, nil
+}
+
+func (i *Image) Volumes() (map[string]struct{}, error) {
+	volumes := make(map[string]struct{}, len(i.inspect.Config.Volumes))
+	for volume := range i.inspect.Config.Volumes {
+		volumes[volume] = struct{}{}
 	}
-	return "", nil
+	return volumes, nil
+}
+
+func (i *Image) StopSignal() (string, error) {
+	return i.inspect.Config.StopSignal, nil
+}
+
+func copyStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append([]string{}, s...)
 }
 
 func (i *Image) OS() (string, error) {
@@ -129,7 +281,7 @@ func (i *Image) Architecture() (string, error) {
 
 func (i *Image) Rename(name string) {
 	i.easyAddLayers = nil
-	if prevInspect, _, err := i.docker.ImageInspectWithRaw(context.TODO(), name); err == nil {
+	if prevInspect, _, err := i.docker.ImageInspectWithRaw(i.ctx, name); err == nil {
 		if i.sameBase(prevInspect) {
 			i.easyAddLayers = prevInspect.RootFS.Layers[len(i.inspect.RootFS.Layers):]
 		}
@@ -174,8 +326,20 @@ func (i *Image) CreatedAt() (time.Time, error) {
 	return createdTime, nil
 }
 
-func (i *Image) Rebase(baseTopLayer string, newBase imgutil.Image) error {
-	ctx := context.Background()
+func (i *Image) Rebase(baseTopLayer string, newBase imgutil.Image, ops ...imgutil.RebaseOption) error {
+	return i.RebaseContext(i.ctx, baseTopLayer, newBase, ops...)
+}
+
+func (i *Image) RebaseContext(ctx context.Context, baseTopLayer string, newBase imgutil.Image, ops ...imgutil.RebaseOption) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := imgutil.ValidateRebase(i, newBase, ops...); err != nil {
+		return err
+	}
+	if err := i.resolveDiffIDs(); err != nil {
+		return err
+	}
 
 	// FIND TOP LAYER
 	keepLayers := -1
@@ -186,42 +350,50 @@ func (i *Image) Rebase(baseTopLayer string, newBase imgutil.Image) error {
 		}
 	}
 	if keepLayers == -1 {
-		return fmt.Errorf("'%s' not found in '%s' during rebase", baseTopLayer, i.repoName)
+		return imgutil.Errorf(imgutil.ErrLayerNotFound, "'%s' not found in '%s' during rebase", baseTopLayer, i.repoName)
 	}
 
 	// SWITCH BASE LAYERS
-	newBaseInspect, _, err := i.docker.ImageInspectWithRaw(ctx, newBase.Name())
-	if err != nil {
-		return errors.Wrap(err, "analyze read previous image config")
+	var layers []string
+	var sources []layerSource
+	if newBaseLocal, ok := newBase.(*Image); ok {
+		layers = append(layers, newBaseLocal.inspect.RootFS.Layers...)
+		sources = append(sources, newBaseLocal.layers...)
+	} else {
+		// the daemon may not have the layers of a new base from another backend, so they are read through its
+		// GetLayer and sent with the image
+		diffIDs, err := newBase.DiffIDs()
+		if err != nil {
+			return errors.Wrapf(err, "get layers of image '%s'", newBase.Name())
+		}
+		for _, diffID := range diffIDs {
+			layers = append(layers, diffID)
+			sources = append(sources, layerSource{path: diffID, image: newBase, scratchDir: i.scratchDir})
+		}
 	}
-	i.inspect.RootFS.Layers = newBaseInspect.RootFS.Layers
-	i.layerPaths = make([]string, len(i.inspect.RootFS.Layers))
+	baseLayers := len(layers)
 
 	// DOWNLOAD IMAGE
-	if err := i.downloadImageOnce(i.repoName); err != nil {
+	if err := i.downloadImageOnce(ctx, i.repoName); err != nil {
 		return err
 	}
 
-	// READ MANIFEST.JSON
-	b, err := ioutil.ReadFile(filepath.Join(i.prevImage.dir, "manifest.json"))
-	if err != nil {
-		return err
-	}
-	var manifest []struct{ Layers []string }
-	if err := json.Unmarshal(b, &manifest); err != nil {
-		return err
+	// ADD EXISTING LAYERS
+	prevLayers, prevDiffIDs := i.prevImage.layers, i.prevImage.diffIDs
+	if len(prevLayers) < keepLayers {
+		return imgutil.Errorf(imgutil.ErrInvalidImage, "image '%s' has %d layers, expected at least %d", i.repoName, len(prevLayers), keepLayers)
 	}
-	if len(manifest) != 1 {
-		return fmt.Errorf("expected 1 image received %d", len(manifest))
+	for idx := len(prevLayers) - keepLayers; idx < len(prevLayers); idx++ {
+		layers = append(layers, prevDiffIDs[idx])
+		sources = append(sources, layerSource{path: prevLayers[idx], archive: i.prevImage})
 	}
 
-	// ADD EXISTING LAYERS
-	for _, filename := range manifest[0].Layers[(len(manifest[0].Layers) - keepLayers):] {
-		if err := i.AddLayer(filepath.Join(i.prevImage.dir, filename)); err != nil {
-			return err
-		}
+	i.inspect.RootFS.Layers = layers
+	i.layers = sources
+	i.baseLayers = baseLayers
+	if keepLayers > 0 {
+		i.easyAddLayers = nil
 	}
-
 	return nil
 }
 
@@ -235,7 +407,7 @@ func (i *Image) SetLabel(key, val string) error {
 }
 
 func (i *Image) SetEnv(key, val string) error {
-	i.inspect.Config.Env = append(i.inspect.Config.Env, fmt.Sprintf("%s=%s", key, val))
+	i.inspect.Config.Env = imgutil.SetEnv(i.inspect.Config.Env, i.inspect.Os, key, val)
 	return nil
 }
 
@@ -254,78 +426,192 @@ func (i *Image) SetCmd(cmd ...string) error {
 	return nil
 }
 
+func (i *Image) SetUser(user string) error {
+	i.inspect.Config.User = user
+	return nil
+}
+
+func (i *Image) SetExposedPorts(ports map[string]struct{}) error {
+	i.inspect.Config.ExposedPorts = nat.PortSet{}
+	for port := range ports {
+		i.inspect.Config.ExposedPorts[nat.Port(port)] = struct{}{}
+	}
+	return nil
+}
+
+func (i *Image) SetVolumes(volumes map[string]struct{}) error {
+	i.inspect.Config.Volumes = map[string]struct{}{}
+	for volume := range volumes {
+		i.inspect.Config.Volumes[volume] = struct{}{}
+	}
+	return nil
+}
+
+func (i *Image) SetHealthcheck(healthcheck imgutil.HealthConfig) error {
+	i.inspect.Config.Healthcheck = &container.HealthConfig{
+		Test:        healthcheck.Test,
+		Interval:    healthcheck.Interval,
+		Timeout:     healthcheck.Timeout,
+		StartPeriod: healthcheck.StartPeriod,
+		Retries:     healthcheck.Retries,
+	}
+	return nil
+}
+
+func (i *Image) SetStopSignal(signal string) error {
+	i.inspect.Config.StopSignal = signal
+	return nil
+}
+
+func (i *Image) SetShell(shell ...string) error {
+	i.inspect.Config.Shell = shell
+	return nil
+}
+
+func (i *Image) SetOnBuild(triggers ...string) error {
+	i.inspect.Config.OnBuild = triggers
+	return nil
+}
+
+func (i *Image) RemoveLabel(key string) error {
+	delete(i.inspect.Config.Labels, key)
+	return nil
+}
+
+func (i *Image) RemoveEnv(key string) error {
+	i.inspect.Config.Env = imgutil.RemoveEnv(i.inspect.Config.Env, i.inspect.Os, key)
+	return nil
+}
+
+func (i *Image) RemoveLayer(diffID string) error {
+	if err := i.resolveDiffIDs(); err != nil {
+		return err
+	}
+	for idx := len(i.inspect.RootFS.Layers) - 1; idx >= 0; idx-- {
+		if i.inspect.RootFS.Layers[idx] != diffID {
+			continue
+		}
+		if idx < i.baseLayers {
+			return imgutil.Errorf(imgutil.ErrBaseLayer, "layer with diff id '%s' belongs to the base of image '%s' and cannot be removed", diffID, i.repoName)
+		}
+		i.inspect.RootFS.Layers = append(i.inspect.RootFS.Layers[:idx:idx], i.inspect.RootFS.Layers[idx+1:]...)
+		i.layers = append(i.layers[:idx:idx], i.layers[idx+1:]...)
+		i.easyAddLayers = nil
+		return nil
+	}
+	return imgutil.Errorf(imgutil.ErrLayerNotFound, "image '%s' has no layer with diff id '%s'", i.repoName, diffID)
+}
+
 func (i *Image) TopLayer() (string, error) {
+	if err := i.resolveDiffIDs(); err != nil {
+		return "", err
+	}
 	all := i.inspect.RootFS.Layers
 
 	if len(all) == 0 {
-		return "", fmt.Errorf("image '%s' has no layers", i.repoName)
+		return "", imgutil.Errorf(imgutil.ErrLayerNotFound, "image '%s' has no layers", i.repoName)
 	}
 
 	topLayer := all[len(all)-1]
 	return topLayer, nil
 }
 
+func (i *Image) DiffIDs() ([]string, error) {
+	if err := i.resolveDiffIDs(); err != nil {
+		return nil, err
+	}
+	return copyStrings(i.inspect.RootFS.Layers), nil
+}
+
 func (i *Image) GetLayer(diffID string) (io.ReadCloser, error) {
-	err := i.downloadImageOnce(i.repoName)
+	err := i.downloadImageOnce(i.ctx, i.repoName)
 	if err != nil {
 		return nil, err
 	}
 
 	layerID, ok := i.prevImage.layersMap[diffID]
 	if !ok {
-		return nil, fmt.Errorf("image '%s' does not contain layer with diff ID '%s'", i.repoName, diffID)
+		return nil, imgutil.Errorf(imgutil.ErrLayerNotFound, "image '%s' does not contain layer with diff ID '%s'", i.repoName, diffID)
 	}
-	return os.Open(filepath.Join(i.prevImage.dir, layerID))
+	rc, _, err := i.prevImage.openEntry(layerID)
+	return rc, err
 }
 
+// AddLayer adds the layer at path. Its diff id is computed while Save sends it to the daemon, or when a method that
+// needs it is called first.
 func (i *Image) AddLayer(path string) error {
-	f, err := os.Open(path)
-	if err != nil {
+	if _, err := os.Stat(path); err != nil {
 		return errors.Wrapf(err, "AddLayer: open layer: %s", path)
 	}
-	defer f.Close()
-	hasher := sha256.New()
-	if _, err := io.Copy(hasher, f); err != nil {
-		return errors.Wrapf(err, "AddLayer: calculate checksum: %s", path)
-	}
-	diffID := "sha256:" + hex.EncodeToString(hasher.Sum(make([]byte, 0, hasher.Size())))
-	return i.AddLayerWithDiffID(path, diffID)
+	i.addLayer("", layerSource{path: path})
+	return nil
 }
 
 func (i *Image) AddLayerWithDiffID(path, diffID string) error {
+	i.addLayer(diffID, layerSource{path: path})
+	return nil
+}
+
+// addLayer adds a layer read from source, whose diff id is empty when it hasn't been computed yet.
+func (i *Image) addLayer(diffID string, source layerSource) {
 	i.inspect.RootFS.Layers = append(i.inspect.RootFS.Layers, diffID)
-	i.layerPaths = append(i.layerPaths, path)
+	i.layers = append(i.layers, source)
 	i.easyAddLayers = nil
+}
+
+// resolveDiffIDs computes the diff ids of added layers that don't have one yet.
+func (i *Image) resolveDiffIDs() error {
+	for idx, diffID := range i.inspect.RootFS.Layers {
+		if diffID != "" {
+			continue
+		}
+		rc, _, err := i.layers[idx].open()
+		if err != nil {
+			return errors.Wrapf(err, "AddLayer: open layer: %s", i.layers[idx].path)
+		}
+		hasher := sha256.New()
+		_, err = io.Copy(hasher, rc)
+		rc.Close()
+		if err != nil {
+			return errors.Wrapf(err, "AddLayer: calculate checksum: %s", i.layers[idx].path)
+		}
+		i.inspect.RootFS.Layers[idx] = "sha256:" + hex.EncodeToString(hasher.Sum(nil))
+	}
 	return nil
 }
 
 func (i *Image) ReuseLayer(diffID string) error {
 	if len(i.easyAddLayers) > 0 && i.easyAddLayers[0] == diffID {
 		i.inspect.RootFS.Layers = append(i.inspect.RootFS.Layers, diffID)
-		i.layerPaths = append(i.layerPaths, "")
+		i.layers = append(i.layers, layerSource{})
 		i.easyAddLayers = i.easyAddLayers[1:]
 		return nil
 	}
 
 	if i.prevName == "" {
-		return errors.New("no previous image provided to reuse layers from")
+		return imgutil.Errorf(imgutil.ErrLayerNotFound, "no previous image provided to reuse layer '%s' from", diffID)
 	}
 
-	err := i.downloadImageOnce(i.prevName)
+	err := i.downloadImageOnce(i.ctx, i.prevName)
 	if err != nil {
 		return err
 	}
 
 	reuseLayer, ok := i.prevImage.layersMap[diffID]
 	if !ok {
-		return fmt.Errorf("SHA %s was not found in %s", diffID, i.repoName)
+		return imgutil.Errorf(imgutil.ErrLayerNotFound, "SHA %s was not found in %s", diffID, i.repoName)
 	}
 
-	return i.AddLayer(filepath.Join(i.prevImage.dir, reuseLayer))
+	i.addLayer(diffID, layerSource{path: reuseLayer, archive: i.prevImage})
+	return nil
 }
 
 func (i *Image) Save(additionalNames ...string) error {
-	inspect, err := i.doSave()
+	return i.SaveContext(i.ctx, additionalNames...)
+}
+
+func (i *Image) SaveContext(ctx context.Context, additionalNames ...string) error {
+	inspect, err := i.doSave(ctx)
 	if err != nil {
 		saveErr := imgutil.SaveError{}
 		for _, n := range append([]string{i.Name()}, additionalNames...) {
@@ -337,7 +623,7 @@ func (i *Image) Save(additionalNames ...string) error {
 
 	var errs []imgutil.SaveDiagnostic
 	for _, n := range append([]string{i.Name()}, additionalNames...) {
-		if err := i.docker.ImageTag(context.Background(), i.inspect.ID, n); err != nil {
+		if err := i.docker.ImageTag(ctx, i.inspect.ID, n); err != nil {
 			errs = append(errs, imgutil.SaveDiagnostic{ImageName: n, Cause: err})
 		}
 	}
@@ -349,9 +635,8 @@ func (i *Image) Save(additionalNames ...string) error {
 	return nil
 }
 
-func (i *Image) doSave() (types.ImageInspect, error) {
-	ctx := context.Background()
-	done := make(chan error)
+func (i *Image) doSave(ctx context.Context) (types.ImageInspect, error) {
+	done := make(chan error, 1)
 
 	t, err := name.NewTag(i.repoName, name.WeakValidation)
 	if err != nil {
@@ -364,14 +649,17 @@ func (i *Image) doSave() (types.ImageInspect, error) {
 	pr, pw := io.Pipe()
 	defer pw.Close()
 	go func() {
-		res, err := i.docker.ImageLoad(ctx, pr, true)
+		// the daemon only streams progress messages when the load isn't quiet
+		res, err := i.docker.ImageLoad(ctx, pr, i.progress == nil)
 		if err != nil {
+			// unblock the tar writer when the load fails or ctx is canceled before the archive is consumed
+			pr.CloseWithError(err)
 			done <- err
 			return
 		}
 
 		//only return response error after response is drained and closed
-		responseErr := checkResponseError(res.Body)
+		responseErr := checkResponseError(res.Body, i.progress)
 		drainCloseErr := ensureReaderClosed(res.Body)
 		if responseErr != nil {
 			done <- responseErr
@@ -379,6 +667,7 @@ func (i *Image) doSave() (types.ImageInspect, error) {
 		}
 		if drainCloseErr != nil {
 			done <- drainCloseErr
+			return
 		}
 
 		done <- nil
@@ -387,35 +676,35 @@ func (i *Image) doSave() (types.ImageInspect, error) {
 	tw := tar.NewWriter(pw)
 	defer tw.Close()
 
-	configFile, err := i.newConfigFile()
-	if err != nil {
-		return types.ImageInspect{}, errors.Wrap(err, "generate config file")
-	}
-
-	id := fmt.Sprintf("%x", sha256.Sum256(configFile))
-	if err := addTextToTar(tw, id+".json", configFile); err != nil {
-		return types.ImageInspect{}, err
-	}
-
+	// the daemon reads the whole archive before its manifest, so layers are written first and the diff ids of added
+	// layers are computed as they are sent
 	var layerPaths []string
-	for _, path := range i.layerPaths {
-		if path == "" {
+	for idx, source := range i.layers {
+		if source.path == "" && source.archive == nil && source.image == nil {
 			layerPaths = append(layerPaths, "")
 			continue
 		}
-		layerName := fmt.Sprintf("/%x.tar", sha256.Sum256([]byte(path)))
-		f, err := os.Open(path)
+		layerName := fmt.Sprintf("/%x.tar", sha256.Sum256([]byte(source.key())))
+		diffID, err := addLayerToTar(tw, layerName, source, i.inspect.RootFS.Layers[idx] == "")
 		if err != nil {
 			return types.ImageInspect{}, err
 		}
-		defer f.Close()
-		if err := addFileToTar(tw, layerName, f); err != nil {
-			return types.ImageInspect{}, err
+		if diffID != "" {
+			i.inspect.RootFS.Layers[idx] = diffID
 		}
-		f.Close()
 		layerPaths = append(layerPaths, layerName)
 	}
 
+	configFile, err := i.newConfigFile()
+	if err != nil {
+		return types.ImageInspect{}, errors.Wrap(err, "generate config file")
+	}
+
+	id := fmt.Sprintf("%x", sha256.Sum256(configFile))
+	if err := addTextToTar(tw, id+".json", configFile); err != nil {
+		return types.ImageInspect{}, err
+	}
+
 	manifest, err := json.Marshal([]map[string]interface{}{
 		{
 			"Config":   id + ".json",
@@ -438,10 +727,10 @@ func (i *Image) doSave() (types.ImageInspect, error) {
 		return types.ImageInspect{}, errors.Wrapf(err, "image load '%s'. first error", i.repoName)
 	}
 
-	inspect, _, err := i.docker.ImageInspectWithRaw(context.Background(), id)
+	inspect, _, err := i.docker.ImageInspectWithRaw(ctx, id)
 	if err != nil {
 		if client.IsErrNotFound(err) {
-			return types.ImageInspect{}, errors.Wrapf(err, "save image '%s'", i.repoName)
+			return types.ImageInspect{}, daemonError(err, "save image '%s'", i.repoName)
 		}
 		return types.ImageInspect{}, err
 	}
@@ -465,87 +754,27 @@ func (i *Image) Delete() error {
 		Force:         true,
 		PruneChildren: true,
 	}
-	_, err := i.docker.ImageRemove(context.Background(), i.inspect.ID, options)
+	_, err := i.docker.ImageRemove(i.ctx, i.inspect.ID, options)
 	return err
 }
 
-func (i *Image) downloadImageOnce(imageName string) error {
-	var err error
+func (i *Image) downloadImageOnce(ctx context.Context, imageName string) error {
 	i.downloadOnce.Do(func() {
-		var fsimg *FileSystemLocalImage
-		fsimg, err = downloadImage(i.docker, imageName)
-		i.prevImage = fsimg
+		i.prevImage, i.downloadErr = downloadImage(ctx, i.docker, imageName, i.scratchDir, i.progress)
 	})
-	return err
+	return i.downloadErr
 }
 
-func downloadImage(docker client.CommonAPIClient, imageName string) (*FileSystemLocalImage, error) {
-	ctx := context.Background()
-
-	imageReader, err := docker.ImageSave(ctx, []string{imageName})
-	if err != nil {
-		return nil, err
-	}
-	defer ensureReaderClosed(imageReader)
-
-	tmpDir, err := ioutil.TempDir("", "imgutil.local.image.")
-	if err != nil {
-		return nil, errors.Wrap(err, "local reuse-layer create temp dir")
-	}
-
-	err = untar(imageReader, tmpDir)
-	if err != nil {
-		return nil, err
-	}
-
-	mf, err := os.Open(filepath.Join(tmpDir, "manifest.json"))
-	if err != nil {
-		return nil, err
-	}
-	defer mf.Close()
-
-	var manifest []struct {
-		Config string
-		Layers []string
-	}
-	if err := json.NewDecoder(mf).Decode(&manifest); err != nil {
-		return nil, err
-	}
-
-	if len(manifest) != 1 {
-		return nil, fmt.Errorf("manifest.json had unexpected number of entries: %d", len(manifest))
-	}
-
-	df, err := os.Open(filepath.Join(tmpDir, manifest[0].Config))
-	if err != nil {
-		return nil, err
-	}
-	defer df.Close()
-
-	var details struct {
-		RootFS struct {
-			DiffIDs []string `json:"diff_ids"`
-		} `json:"rootfs"`
-	}
-
-	if err = json.NewDecoder(df).Decode(&details); err != nil {
-		return nil, err
-	}
-
-	if len(manifest[0].Layers) != len(details.RootFS.DiffIDs) {
-		return nil, fmt.Errorf("layers and diff IDs do not match, there are %d layers and %d diffIDs", len(manifest[0].Layers), len(details.RootFS.DiffIDs))
+// Cleanup removes the archive of the image read from the daemon to reuse or rebase layers, if any. Layers reused
+// from it can't be saved afterwards.
+func (i *Image) Cleanup() error {
+	if i.prevImage == nil {
+		return nil
 	}
-
-	layersMap := make(map[string]string, len(manifest[0].Layers))
-	for i, diffID := range details.RootFS.DiffIDs {
-		layerID := manifest[0].Layers[i]
-		layersMap[diffID] = layerID
+	if err := os.Remove(i.prevImage.archive); err != nil && !os.IsNotExist(err) {
+		return err
 	}
-
-	return &FileSystemLocalImage{
-		dir:       tmpDir,
-		layersMap: layersMap,
-	}, nil
+	return nil
 }
 
 func addTextToTar(tw *tar.Writer, name string, contents []byte) error {
@@ -557,84 +786,61 @@ func addTextToTar(tw *tar.Writer, name string, contents []byte) error {
 	return err
 }
 
-func addFileToTar(tw *tar.Writer, name string, contents *os.File) error {
-	fi, err := contents.Stat()
+// addLayerToTar writes the layer read from source to tw as name, returning its diff id when hash is set.
+func addLayerToTar(tw *tar.Writer, name string, source layerSource, hash bool) (string, error) {
+	rc, size, err := source.open()
 	if err != nil {
-		return err
+		return "", err
 	}
-	hdr := &tar.Header{Name: name, Mode: 0644, Size: fi.Size()}
+	defer rc.Close()
+
+	hdr := &tar.Header{Name: name, Mode: 0644, Size: size}
 	if err := tw.WriteHeader(hdr); err != nil {
-		return err
+		return "", err
 	}
-	_, err = io.Copy(tw, contents)
-	return err
-}
-
-func untar(r io.Reader, dest string) error {
-	tr := tar.NewReader(r)
-	for {
-		hdr, err := tr.Next()
-		if err == io.EOF {
-			// end of tar archive
-			return nil
-		}
-		if err != nil {
-			return err
-		}
-
-		path := filepath.Join(dest, hdr.Name)
-
-		switch hdr.Typeflag {
-		case tar.TypeDir:
-			if err := os.MkdirAll(path, hdr.FileInfo().Mode()); err != nil {
-				return err
-			}
-		case tar.TypeReg, tar.TypeRegA:
-			_, err := os.Stat(filepath.Dir(path))
-			if os.IsNotExist(err) {
-				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-					return err
-				}
-			}
-
-			fh, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, hdr.FileInfo().Mode())
-			if err != nil {
-				return err
-			}
-			if _, err := io.Copy(fh, tr); err != nil {
-				fh.Close()
-				return err
-			}
-			fh.Close()
-		case tar.TypeSymlink:
-			if err := os.Symlink(hdr.Linkname, path); err != nil {
-				return err
-			}
-		default:
-			return fmt.Errorf("unknown file type in tar %d", hdr.Typeflag)
-		}
+	if !hash {
+		_, err = io.Copy(tw, rc)
+		return "", err
 	}
+	hasher := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tw, hasher), rc); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(hasher.Sum(nil)), nil
 }
 
-func inspectOptionalImage(docker client.CommonAPIClient, imageName string) (types.ImageInspect, error) {
+func inspectOptionalImage(ctx context.Context, docker client.CommonAPIClient, imageName string) (types.ImageInspect, error) {
 	var (
 		err     error
 		inspect types.ImageInspect
 	)
 
-	if inspect, _, err = docker.ImageInspectWithRaw(context.Background(), imageName); err != nil {
+	if inspect, _, err = docker.ImageInspectWithRaw(ctx, imageName); err != nil {
 		if client.IsErrNotFound(err) {
-			return defaultInspect(docker)
+			return defaultInspect(ctx, docker)
 		}
 
-		return types.ImageInspect{}, errors.Wrapf(err, "verifying image '%s'", imageName)
+		return types.ImageInspect{}, daemonError(err, "verifying image '%s'", imageName)
 	}
 
 	return inspect, nil
 }
 
-func defaultInspect(docker client.CommonAPIClient) (types.ImageInspect, error) {
-	daemonInfo, err := docker.Info(context.Background())
+// daemonError wraps an error returned by the daemon, classifying it as one of the imgutil sentinel errors when the
+// daemon reported the image as missing or access as unauthorized.
+func daemonError(err error, format string, args ...interface{}) error {
+	switch {
+	case client.IsErrNotFound(err):
+		return imgutil.Wrapf(imgutil.ErrImageNotFound, err, format, args...)
+	case client.IsErrUnauthorized(err):
+		return imgutil.Wrapf(imgutil.ErrUnauthorized, err, format, args...)
+	default:
+		return errors.Wrapf(err, format, args...)
+	}
+}
+
+func defaultInspect(ctx context.Context, docker client.CommonAPIClient) (types.ImageInspect, error) {
+	daemonInfo, err := docker.Info(ctx)
 	if err != nil {
 		return types.ImageInspect{}, err
 	}
@@ -720,17 +926,35 @@ func v1Config(inspect types.ImageInspect) (v1.ConfigFile, error) {
 	}, nil
 }
 
-func checkResponseError(r io.Reader) error {
+// checkResponseError returns the first error embedded in the daemon's stream of messages.
+// Progress messages in the stream are passed on to progress when it is set.
+func checkResponseError(r io.Reader, progress imgutil.ProgressFunc) error {
 	decoder := json.NewDecoder(r)
-	var jsonMessage jsonmessage.JSONMessage
-	if err := decoder.Decode(&jsonMessage); err != nil {
-		return errors.Wrapf(err, "parsing daemon response")
-	}
+	for first := true; ; first = false {
+		var jsonMessage jsonmessage.JSONMessage
+		if err := decoder.Decode(&jsonMessage); err != nil {
+			if err == io.EOF && !first {
+				return nil
+			}
+			return errors.Wrapf(err, "parsing daemon response")
+		}
 
-	if jsonMessage.Error != nil {
-		return errors.Wrap(jsonMessage.Error, "embedded daemon response")
+		if jsonMessage.Error != nil {
+			return errors.Wrap(jsonMessage.Error, "embedded daemon response")
+		}
+		if progress != nil && jsonMessage.Progress != nil {
+			total := jsonMessage.Progress.Total
+			if total <= 0 {
+				total = -1
+			}
+			progress(imgutil.Progress{
+				ID:          jsonMessage.ID,
+				Transferred: jsonMessage.Progress.Current,
+				Total:       total,
+				Complete:    total > 0 && jsonMessage.Progress.Current >= total,
+			})
+		}
 	}
-	return nil
 }
 
 // ensureReaderClosed drains and closes and reader, returning the first error
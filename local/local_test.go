@@ -3,22 +3,29 @@ package local_test
 import (
 	"archive/tar"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/client"
+	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/sclevine/spec"
 	"github.com/sclevine/spec/report"
 
 	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/imgutiltest"
+	"github.com/buildpacks/imgutil/layout"
 	"github.com/buildpacks/imgutil/local"
+	"github.com/buildpacks/imgutil/remote"
 	h "github.com/buildpacks/imgutil/testhelpers"
 )
 
@@ -32,6 +39,42 @@ func TestLocal(t *testing.T) {
 	defer localTestRegistry.Stop(t)
 
 	spec.Run(t, "Image", testImage, spec.Sequential(), spec.Report(report.Terminal{}))
+
+	dockerClient := h.DockerCli(t)
+	daemonInfo, err := dockerClient.Info(context.TODO())
+	h.AssertNil(t, err)
+
+	var (
+		namesMu sync.Mutex
+		names   []string
+	)
+	defer func() {
+		// not every name is saved, so errors for missing images are expected
+		_ = h.DockerRmi(dockerClient, names...)
+	}()
+
+	imgutiltest.Run(t, imgutiltest.Factory{
+		NewName: func(t *testing.T) string {
+			name := newTestImageName()
+			namesMu.Lock()
+			names = append(names, name)
+			namesMu.Unlock()
+			return name
+		},
+		NewImage: func(t *testing.T, name, baseName, prevName string) imgutil.Image {
+			var ops []local.ImageOption
+			if baseName != "" {
+				ops = append(ops, local.FromBaseImage(baseName))
+			}
+			if prevName != "" {
+				ops = append(ops, local.WithPreviousImage(prevName))
+			}
+			img, err := local.NewImage(name, dockerClient, ops...)
+			h.AssertNil(t, err)
+			return img
+		},
+		LayerOS: daemonInfo.OSType,
+	})
 }
 
 func newTestImageName() string {
@@ -187,9 +230,24 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 				})
 			})
 		})
+
+		when("#WithContext", func() {
+			it("uses the context for daemon requests", func() {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+
+				_, err := local.NewImage(
+					newTestImageName(),
+					dockerClient,
+					local.WithContext(ctx),
+					local.FromBaseImage(runnableBaseImageName),
+				)
+				h.AssertError(t, err, "context canceled")
+			})
+		})
 	})
 
-	when("#Label", func() {
+	when("config getters", func() {
 		when("image exists", func() {
 			var repoName = newTestImageName()
 
@@ -197,8 +255,11 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 				existingImage, err := local.NewImage(repoName, dockerClient)
 				h.AssertNil(t, err)
 
-				h.AssertNil(t, existingImage.SetLabel("mykey", "myvalue"))
-				h.AssertNil(t, existingImage.SetLabel("other", "data"))
+				h.AssertNil(t, existingImage.SetLabel("some.label", "some.value"))
+				h.AssertNil(t, existingImage.SetEnv("MY_VAR", "my=val"))
+				h.AssertNil(t, existingImage.SetEntrypoint("some", "entrypoint"))
+				h.AssertNil(t, existingImage.SetCmd("some", "cmd"))
+				h.AssertNil(t, existingImage.SetWorkingDir("/some/work/dir"))
 				h.AssertNil(t, existingImage.Save())
 			})
 
@@ -206,80 +267,52 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 				h.AssertNil(t, h.DockerRmi(dockerClient, repoName))
 			})
 
-			it("returns the label value", func() {
+			it("returns the config values", func() {
 				img, err := local.NewImage(repoName, dockerClient, local.FromBaseImage(repoName))
 				h.AssertNil(t, err)
 
-				label, err := img.Label("mykey")
+				labels, err := img.Labels()
 				h.AssertNil(t, err)
-				h.AssertEq(t, label, "myvalue")
-			})
+				h.AssertEq(t, labels["some.label"], "some.value")
 
-			it("returns an empty string for a missing label", func() {
-				img, err := local.NewImage(repoName, dockerClient)
+				envVars, err := img.EnvVars()
 				h.AssertNil(t, err)
+				h.AssertEq(t, envVars["MY_VAR"], "my=val")
 
-				label, err := img.Label("missing-label")
+				entrypoint, err := img.Entrypoint()
 				h.AssertNil(t, err)
-				h.AssertEq(t, label, "")
-			})
-		})
+				h.AssertEq(t, entrypoint, []string{"some", "entrypoint"})
 
-		when("image NOT exists", func() {
-			it("returns an empty string", func() {
-				img, err := local.NewImage(newTestImageName(), dockerClient)
+				cmd, err := img.Cmd()
 				h.AssertNil(t, err)
+				h.AssertEq(t, cmd, []string{"some", "cmd"})
 
-				label, err := img.Label("some-label")
+				workingDir, err := img.WorkingDir()
 				h.AssertNil(t, err)
-				h.AssertEq(t, label, "")
+				h.AssertEq(t, workingDir, "/some/work/dir")
 			})
 		})
-	})
-
-	when("#Env", func() {
-		when("image exists", func() {
-			var repoName = newTestImageName()
-
-			it.Before(func() {
-				existingImage, err := local.NewImage(repoName, dockerClient)
-				h.AssertNil(t, err)
-
-				h.AssertNil(t, existingImage.SetEnv("MY_VAR", "my_val"))
-				h.AssertNil(t, existingImage.Save())
-			})
 
-			it.After(func() {
-				h.AssertNil(t, h.DockerRmi(dockerClient, repoName))
-			})
-
-			it("returns the label value", func() {
-				img, err := local.NewImage(repoName, dockerClient, local.FromBaseImage(repoName))
-				h.AssertNil(t, err)
-
-				val, err := img.Env("MY_VAR")
+		when("image NOT exists", func() {
+			it("returns zero values", func() {
+				img, err := local.NewImage(newTestImageName(), dockerClient)
 				h.AssertNil(t, err)
-				h.AssertEq(t, val, "my_val")
-			})
 
-			it("returns an empty string for a missing label", func() {
-				img, err := local.NewImage(repoName, dockerClient, local.FromBaseImage(repoName))
+				user, err := img.User()
 				h.AssertNil(t, err)
+				h.AssertEq(t, user, "")
 
-				val, err := img.Env("MISSING_VAR")
+				ports, err := img.ExposedPorts()
 				h.AssertNil(t, err)
-				h.AssertEq(t, val, "")
-			})
-		})
+				h.AssertEq(t, len(ports), 0)
 
-		when("image NOT exists", func() {
-			it("returns an empty string", func() {
-				img, err := local.NewImage(newTestImageName(), dockerClient)
+				volumes, err := img.Volumes()
 				h.AssertNil(t, err)
+				h.AssertEq(t, len(volumes), 0)
 
-				val, err := img.Env("SOME_VAR")
+				stopSignal, err := img.StopSignal()
 				h.AssertNil(t, err)
-				h.AssertEq(t, val, "")
+				h.AssertEq(t, stopSignal, "")
 			})
 		})
 	})
@@ -368,86 +401,41 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 		})
 	})
 
-	when("#SetLabel", func() {
-		var (
-			img           imgutil.Image
-			repoName      = newTestImageName()
-			baseImageName = newTestImageName()
-		)
+	when("#SetWorkingDir", func() {
+		var repoName = newTestImageName()
 
 		it.After(func() {
-			h.AssertNil(t, h.DockerRmi(dockerClient, repoName, baseImageName))
-		})
-
-		when("base image has labels", func() {
-			it("sets label and saves label to docker daemon", func() {
-				var err error
-
-				baseImage, err := local.NewImage(baseImageName, dockerClient)
-				h.AssertNil(t, err)
-
-				h.AssertNil(t, baseImage.SetLabel("some-key", "some-value"))
-				h.AssertNil(t, baseImage.Save())
-
-				img, err = local.NewImage(repoName, dockerClient, local.FromBaseImage(baseImageName))
-				h.AssertNil(t, err)
-
-				h.AssertNil(t, img.SetLabel("somekey", "new-val"))
-
-				label, err := img.Label("somekey")
-				h.AssertNil(t, err)
-				h.AssertEq(t, label, "new-val")
-
-				h.AssertNil(t, img.Save())
-
-				inspect, _, err := dockerClient.ImageInspectWithRaw(context.TODO(), repoName)
-				h.AssertNil(t, err)
-				label = inspect.Config.Labels["somekey"]
-				h.AssertEq(t, strings.TrimSpace(label), "new-val")
-			})
+			h.AssertNil(t, h.DockerRmi(dockerClient, repoName))
 		})
 
-		when("no labels exists", func() {
-			it("sets label and saves label to docker daemon", func() {
-				var err error
-				baseImage, err := local.NewImage(baseImageName, dockerClient)
-				h.AssertNil(t, err)
-
-				h.AssertNil(t, baseImage.SetCmd("/usr/bin/run"))
-				h.AssertNil(t, baseImage.Save())
-
-				img, err = local.NewImage(repoName, dockerClient, local.FromBaseImage(baseImageName))
-				h.AssertNil(t, err)
-
-				h.AssertNil(t, img.SetLabel("somekey", "new-val"))
+		it("sets the environment", func() {
+			img, err := local.NewImage(repoName, dockerClient)
+			h.AssertNil(t, err)
 
-				label, err := img.Label("somekey")
-				h.AssertNil(t, err)
-				h.AssertEq(t, label, "new-val")
+			err = img.SetWorkingDir("/some/work/dir")
+			h.AssertNil(t, err)
 
-				h.AssertNil(t, img.Save())
+			h.AssertNil(t, img.Save())
 
-				inspect, _, err := dockerClient.ImageInspectWithRaw(context.TODO(), repoName)
-				h.AssertNil(t, err)
+			inspect, _, err := dockerClient.ImageInspectWithRaw(context.TODO(), repoName)
+			h.AssertNil(t, err)
 
-				label = inspect.Config.Labels["somekey"]
-				h.AssertEq(t, strings.TrimSpace(label), "new-val")
-			})
+			h.AssertEq(t, inspect.Config.WorkingDir, "/some/work/dir")
 		})
 	})
 
-	when("#SetEnv", func() {
+	when("#SetEntrypoint", func() {
 		var repoName = newTestImageName()
 
 		it.After(func() {
 			h.AssertNil(t, h.DockerRmi(dockerClient, repoName))
 		})
 
-		it("sets the environment", func() {
+		it("sets the entrypoint", func() {
 			img, err := local.NewImage(repoName, dockerClient)
 			h.AssertNil(t, err)
 
-			err = img.SetEnv("ENV_KEY", "ENV_VAL")
+			err = img.SetEntrypoint("some", "entrypoint")
 			h.AssertNil(t, err)
 
 			h.AssertNil(t, img.Save())
@@ -455,22 +443,22 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 			inspect, _, err := dockerClient.ImageInspectWithRaw(context.TODO(), repoName)
 			h.AssertNil(t, err)
 
-			h.AssertContains(t, inspect.Config.Env, "ENV_KEY=ENV_VAL")
+			h.AssertEq(t, []string(inspect.Config.Entrypoint), []string{"some", "entrypoint"})
 		})
 	})
 
-	when("#SetWorkingDir", func() {
+	when("#SetCmd", func() {
 		var repoName = newTestImageName()
 
 		it.After(func() {
 			h.AssertNil(t, h.DockerRmi(dockerClient, repoName))
 		})
 
-		it("sets the environment", func() {
+		it("sets the cmd", func() {
 			img, err := local.NewImage(repoName, dockerClient)
 			h.AssertNil(t, err)
 
-			err = img.SetWorkingDir("/some/work/dir")
+			err = img.SetCmd("some", "cmd")
 			h.AssertNil(t, err)
 
 			h.AssertNil(t, img.Save())
@@ -478,284 +466,206 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 			inspect, _, err := dockerClient.ImageInspectWithRaw(context.TODO(), repoName)
 			h.AssertNil(t, err)
 
-			h.AssertEq(t, inspect.Config.WorkingDir, "/some/work/dir")
+			h.AssertEq(t, []string(inspect.Config.Cmd), []string{"some", "cmd"})
 		})
 	})
 
-	when("#SetEntrypoint", func() {
+	when("config setters", func() {
 		var repoName = newTestImageName()
 
 		it.After(func() {
 			h.AssertNil(t, h.DockerRmi(dockerClient, repoName))
 		})
 
-		it("sets the entrypoint", func() {
+		it("sets the config values", func() {
 			img, err := local.NewImage(repoName, dockerClient)
 			h.AssertNil(t, err)
 
-			err = img.SetEntrypoint("some", "entrypoint")
-			h.AssertNil(t, err)
+			h.AssertNil(t, img.SetUser("some-user"))
+			h.AssertNil(t, img.SetExposedPorts(map[string]struct{}{"8080/tcp": {}}))
+			h.AssertNil(t, img.SetVolumes(map[string]struct{}{"/some/volume": {}}))
+			h.AssertNil(t, img.SetHealthcheck(imgutil.HealthConfig{
+				Test:     []string{"CMD", "some-check"},
+				Interval: 30 * time.Second,
+				Retries:  3,
+			}))
+			h.AssertNil(t, img.SetStopSignal("SIGKILL"))
+			h.AssertNil(t, img.SetShell("/bin/bash", "-c"))
+			h.AssertNil(t, img.SetOnBuild("RUN some-trigger"))
 
 			h.AssertNil(t, img.Save())
 
 			inspect, _, err := dockerClient.ImageInspectWithRaw(context.TODO(), repoName)
 			h.AssertNil(t, err)
 
-			h.AssertEq(t, []string(inspect.Config.Entrypoint), []string{"some", "entrypoint"})
+			h.AssertEq(t, inspect.Config.User, "some-user")
+			_, ok := inspect.Config.ExposedPorts["8080/tcp"]
+			h.AssertEq(t, ok, true)
+			_, ok = inspect.Config.Volumes["/some/volume"]
+			h.AssertEq(t, ok, true)
+			h.AssertEq(t, inspect.Config.Healthcheck.Test, []string{"CMD", "some-check"})
+			h.AssertEq(t, inspect.Config.Healthcheck.Interval, 30*time.Second)
+			h.AssertEq(t, inspect.Config.Healthcheck.Retries, 3)
+			h.AssertEq(t, inspect.Config.StopSignal, "SIGKILL")
+			h.AssertEq(t, []string(inspect.Config.Shell), []string{"/bin/bash", "-c"})
+			h.AssertEq(t, inspect.Config.OnBuild, []string{"RUN some-trigger"})
 		})
 	})
 
-	when("#SetCmd", func() {
+	when("#RemoveLayer", func() {
 		var repoName = newTestImageName()
 
 		it.After(func() {
 			h.AssertNil(t, h.DockerRmi(dockerClient, repoName))
 		})
 
-		it("sets the cmd", func() {
-			img, err := local.NewImage(repoName, dockerClient)
+		it("removes an added layer", func() {
+			img, err := local.NewImage(repoName, dockerClient, local.FromBaseImage(runnableBaseImageName))
 			h.AssertNil(t, err)
 
-			err = img.SetCmd("some", "cmd")
+			layer1Path, err := h.CreateSingleFileLayerTar("/layer-1.txt", "layer-1", daemonOS)
+			h.AssertNil(t, err)
+			defer os.Remove(layer1Path)
+			layer2Path, err := h.CreateSingleFileLayerTar("/layer-2.txt", "layer-2", daemonOS)
 			h.AssertNil(t, err)
+			defer os.Remove(layer2Path)
+
+			h.AssertNil(t, img.AddLayer(layer1Path))
+			h.AssertNil(t, img.AddLayer(layer2Path))
+			h.AssertNil(t, img.RemoveLayer(h.FileDiffID(t, layer1Path)))
 
 			h.AssertNil(t, img.Save())
 
 			inspect, _, err := dockerClient.ImageInspectWithRaw(context.TODO(), repoName)
 			h.AssertNil(t, err)
 
-			h.AssertEq(t, []string(inspect.Config.Cmd), []string{"some", "cmd"})
+			h.AssertDoesNotContain(t, inspect.RootFS.Layers, h.FileDiffID(t, layer1Path))
+			h.AssertEq(t, inspect.RootFS.Layers[len(inspect.RootFS.Layers)-1], h.FileDiffID(t, layer2Path))
+		})
+
+		it("returns an error for a base image layer", func() {
+			img, err := local.NewImage(repoName, dockerClient, local.FromBaseImage(runnableBaseImageName))
+			h.AssertNil(t, err)
+
+			baseTopLayer, err := img.TopLayer()
+			h.AssertNil(t, err)
+
+			err = img.RemoveLayer(baseTopLayer)
+			h.AssertError(t, err, "belongs to the base of image")
+			h.AssertEq(t, errors.Is(err, imgutil.ErrBaseLayer), true)
+
+			h.AssertNil(t, img.Save())
 		})
 	})
 
-	when("#Rebase", func() {
+	when("#TopLayer", func() {
 		when("image exists", func() {
 			var (
-				repoName                              = newTestImageName()
-				oldBase, oldTopLayer, newBase, origID string
-				oldBaseLayer1DiffID                   string
-				oldBaseLayer2DiffID                   string
-				newBaseLayer1DiffID                   string
-				newBaseLayer2DiffID                   string
-				imgLayer1DiffID                       string
-				imgLayer2DiffID                       string
-				origNumLayers                         int
+				expectedTopLayer string
+				repoName         = newTestImageName()
 			)
-
 			it.Before(func() {
-				// new base image
-				newBase = "pack-newbase-test-" + h.RandString(10)
-				newBaseImage, err := local.NewImage(newBase, dockerClient, local.FromBaseImage(runnableBaseImageName))
+				existingImage, err := local.NewImage(
+					repoName,
+					dockerClient,
+					local.FromBaseImage(runnableBaseImageName),
+				)
 				h.AssertNil(t, err)
 
-				newBaseLayer1Path, err := h.CreateSingleFileLayerTar("/new-base.txt", "new-base", daemonOS)
+				layer1Path, err := h.CreateSingleFileLayerTar("/newfile.txt", "old-base", daemonOS)
 				h.AssertNil(t, err)
-				defer os.Remove(newBaseLayer1Path)
-
-				newBaseLayer1DiffID = h.FileDiffID(t, newBaseLayer1Path)
-
-				newBaseLayer2Path, err := h.CreateSingleFileLayerTar("/otherfile.txt", "text-new-base", daemonOS)
+				layer2Path, err := h.CreateSingleFileLayerTar("/otherfile.txt", "text-old-base", daemonOS)
 				h.AssertNil(t, err)
-				defer os.Remove(newBaseLayer2Path)
-
-				newBaseLayer2DiffID = h.FileDiffID(t, newBaseLayer2Path)
 
-				h.AssertNil(t, newBaseImage.AddLayer(newBaseLayer1Path))
-				h.AssertNil(t, newBaseImage.AddLayer(newBaseLayer2Path))
-
-				h.AssertNil(t, newBaseImage.Save())
+				h.AssertNil(t, existingImage.AddLayer(layer1Path))
+				h.AssertNil(t, existingImage.AddLayer(layer2Path))
 
-				// old base image
-				oldBase = "pack-oldbase-test-" + h.RandString(10)
-				oldBaseImage, err := local.NewImage(oldBase, dockerClient, local.FromBaseImage(runnableBaseImageName))
-				h.AssertNil(t, err)
+				h.AssertNil(t, existingImage.Save())
 
-				oldBaseLayer1Path, err := h.CreateSingleFileLayerTar("/old-base.txt", "old-base", daemonOS)
+				inspect, _, err := dockerClient.ImageInspectWithRaw(context.TODO(), repoName)
 				h.AssertNil(t, err)
-				defer os.Remove(oldBaseLayer1Path)
+				expectedTopLayer = inspect.RootFS.Layers[len(inspect.RootFS.Layers)-1]
+			})
 
-				oldBaseLayer1DiffID = h.FileDiffID(t, oldBaseLayer1Path)
+			it.After(func() {
+				h.AssertNil(t, h.DockerRmi(dockerClient, repoName))
+			})
 
-				oldBaseLayer2Path, err := h.CreateSingleFileLayerTar("/otherfile.txt", "text-old-base", daemonOS)
+			it("returns the digest for the top layer (useful for rebasing)", func() {
+				img, err := local.NewImage(repoName, dockerClient, local.FromBaseImage(repoName))
 				h.AssertNil(t, err)
-				defer os.Remove(oldBaseLayer2Path)
 
-				oldBaseLayer2DiffID = h.FileDiffID(t, oldBaseLayer2Path)
-
-				h.AssertNil(t, oldBaseImage.AddLayer(oldBaseLayer1Path))
-				h.AssertNil(t, oldBaseImage.AddLayer(oldBaseLayer2Path))
+				actualTopLayer, err := img.TopLayer()
+				h.AssertNil(t, err)
 
-				h.AssertNil(t, oldBaseImage.Save())
+				h.AssertEq(t, actualTopLayer, expectedTopLayer)
+			})
+		})
 
-				inspect, _, err := dockerClient.ImageInspectWithRaw(context.TODO(), oldBase)
+		when("image has no layers", func() {
+			it("returns error", func() {
+				img, err := local.NewImage(newTestImageName(), dockerClient)
 				h.AssertNil(t, err)
-				oldTopLayer = inspect.RootFS.Layers[len(inspect.RootFS.Layers)-1]
 
-				// original image
-				origImage, err := local.NewImage(repoName, dockerClient, local.FromBaseImage(oldBase))
-				h.AssertNil(t, err)
+				_, err = img.TopLayer()
+				h.AssertError(t, err, "has no layers")
+				h.AssertEq(t, errors.Is(err, imgutil.ErrLayerNotFound), true)
+			})
+		})
+	})
 
-				imgLayer1Path, err := h.CreateSingleFileLayerTar("/myimage.txt", "text-from-image", daemonOS)
+	when("#AddLayer", func() {
+		when("empty image", func() {
+			var repoName = newTestImageName()
+
+			it("appends a layer", func() {
+				img, err := local.NewImage(repoName, dockerClient)
 				h.AssertNil(t, err)
-				defer os.Remove(imgLayer1Path)
 
-				imgLayer1DiffID = h.FileDiffID(t, imgLayer1Path)
+				// windows daemons *always* require a valid windows base layer
+				if daemonOS == "windows" {
+					windowsBaseLayer := h.WindowsBaseLayer(t)
+					h.AssertNil(t, img.AddLayer(windowsBaseLayer))
+					defer os.Remove(windowsBaseLayer)
+				}
 
-				imgLayer2Path, err := h.CreateSingleFileLayerTar("/myimage2.txt", "text-from-image", daemonOS)
+				newLayerPath, err := h.CreateSingleFileLayerTar("/new-layer.txt", "new-layer", daemonOS)
 				h.AssertNil(t, err)
-				defer os.Remove(imgLayer2Path)
+				defer os.Remove(newLayerPath)
 
-				imgLayer2DiffID = h.FileDiffID(t, imgLayer2Path)
+				newLayerDiffID := h.FileDiffID(t, newLayerPath)
 
-				h.AssertNil(t, origImage.AddLayer(imgLayer1Path))
-				h.AssertNil(t, origImage.AddLayer(imgLayer2Path))
+				h.AssertNil(t, img.AddLayer(newLayerPath))
 
-				h.AssertNil(t, origImage.Save())
+				h.AssertNil(t, img.Save())
+				defer h.DockerRmi(dockerClient, repoName)
 
-				inspect, _, err = dockerClient.ImageInspectWithRaw(context.TODO(), repoName)
+				inspect, _, err := dockerClient.ImageInspectWithRaw(context.TODO(), repoName)
 				h.AssertNil(t, err)
-				origNumLayers = len(inspect.RootFS.Layers)
-				origID = inspect.ID
-			})
 
-			it.After(func() {
-				h.AssertNil(t, h.DockerRmi(dockerClient, repoName, oldBase, newBase, origID))
+				h.AssertEq(t, newLayerDiffID, inspect.RootFS.Layers[len(inspect.RootFS.Layers)-1])
 			})
 
-			it("switches the base", func() {
-				// Before
-				beforeInspect, _, err := dockerClient.ImageInspectWithRaw(context.TODO(), repoName)
+			it("computes the diff id of the layer before saving when it is needed", func() {
+				img, err := local.NewImage(repoName, dockerClient)
 				h.AssertNil(t, err)
 
-				beforeOldBaseLayer1DiffID := beforeInspect.RootFS.Layers[len(beforeInspect.RootFS.Layers)-4]
-				h.AssertEq(t, oldBaseLayer1DiffID, beforeOldBaseLayer1DiffID)
+				newLayerPath, err := h.CreateSingleFileLayerTar("/new-layer.txt", "new-layer", daemonOS)
+				h.AssertNil(t, err)
+				defer os.Remove(newLayerPath)
 
-				beforeOldBaseLayer2DiffID := beforeInspect.RootFS.Layers[len(beforeInspect.RootFS.Layers)-3]
-				h.AssertEq(t, oldBaseLayer2DiffID, beforeOldBaseLayer2DiffID)
+				h.AssertNil(t, img.AddLayer(newLayerPath))
 
-				beforeLayer3DiffID := beforeInspect.RootFS.Layers[len(beforeInspect.RootFS.Layers)-2]
-				h.AssertEq(t, imgLayer1DiffID, beforeLayer3DiffID)
-
-				beforeLayer4DiffID := beforeInspect.RootFS.Layers[len(beforeInspect.RootFS.Layers)-1]
-				h.AssertEq(t, imgLayer2DiffID, beforeLayer4DiffID)
-
-				// Run rebase
-				img, err := local.NewImage(repoName, dockerClient, local.FromBaseImage(repoName))
-				h.AssertNil(t, err)
-				newBaseImg, err := local.NewImage(newBase, dockerClient, local.FromBaseImage(newBase))
-				h.AssertNil(t, err)
-				err = img.Rebase(oldTopLayer, newBaseImg)
-				h.AssertNil(t, err)
-
-				h.AssertNil(t, img.Save())
-
-				// After
-				afterInspect, _, err := dockerClient.ImageInspectWithRaw(context.TODO(), repoName)
-				h.AssertNil(t, err)
-
-				numLayers := len(afterInspect.RootFS.Layers)
-				h.AssertEq(t, numLayers, origNumLayers)
-
-				afterLayer1DiffID := afterInspect.RootFS.Layers[len(afterInspect.RootFS.Layers)-4]
-				h.AssertEq(t, newBaseLayer1DiffID, afterLayer1DiffID)
-
-				afterLayer2DiffID := afterInspect.RootFS.Layers[len(afterInspect.RootFS.Layers)-3]
-				h.AssertEq(t, newBaseLayer2DiffID, afterLayer2DiffID)
-
-				afterLayer3DiffID := afterInspect.RootFS.Layers[len(afterInspect.RootFS.Layers)-2]
-				h.AssertEq(t, imgLayer1DiffID, afterLayer3DiffID)
-
-				afterLayer4DiffID := afterInspect.RootFS.Layers[len(afterInspect.RootFS.Layers)-1]
-				h.AssertEq(t, imgLayer2DiffID, afterLayer4DiffID)
-			})
-		})
-	})
-
-	when("#TopLayer", func() {
-		when("image exists", func() {
-			var (
-				expectedTopLayer string
-				repoName         = newTestImageName()
-			)
-			it.Before(func() {
-				existingImage, err := local.NewImage(
-					repoName,
-					dockerClient,
-					local.FromBaseImage(runnableBaseImageName),
-				)
+				topLayer, err := img.TopLayer()
 				h.AssertNil(t, err)
-
-				layer1Path, err := h.CreateSingleFileLayerTar("/newfile.txt", "old-base", daemonOS)
-				h.AssertNil(t, err)
-				layer2Path, err := h.CreateSingleFileLayerTar("/otherfile.txt", "text-old-base", daemonOS)
-				h.AssertNil(t, err)
-
-				h.AssertNil(t, existingImage.AddLayer(layer1Path))
-				h.AssertNil(t, existingImage.AddLayer(layer2Path))
-
-				h.AssertNil(t, existingImage.Save())
-
-				inspect, _, err := dockerClient.ImageInspectWithRaw(context.TODO(), repoName)
-				h.AssertNil(t, err)
-				expectedTopLayer = inspect.RootFS.Layers[len(inspect.RootFS.Layers)-1]
-			})
-
-			it.After(func() {
-				h.AssertNil(t, h.DockerRmi(dockerClient, repoName))
-			})
-
-			it("returns the digest for the top layer (useful for rebasing)", func() {
-				img, err := local.NewImage(repoName, dockerClient, local.FromBaseImage(repoName))
-				h.AssertNil(t, err)
-
-				actualTopLayer, err := img.TopLayer()
-				h.AssertNil(t, err)
-
-				h.AssertEq(t, actualTopLayer, expectedTopLayer)
-			})
-		})
-
-		when("image has no layers", func() {
-			it("returns error", func() {
-				img, err := local.NewImage(newTestImageName(), dockerClient)
-				h.AssertNil(t, err)
-
-				_, err = img.TopLayer()
-				h.AssertError(t, err, "has no layers")
+				h.AssertEq(t, topLayer, h.FileDiffID(t, newLayerPath))
 			})
-		})
-	})
-
-	when("#AddLayer", func() {
-		when("empty image", func() {
-			var repoName = newTestImageName()
 
-			it("appends a layer", func() {
+			it("returns an error when the layer doesn't exist", func() {
 				img, err := local.NewImage(repoName, dockerClient)
 				h.AssertNil(t, err)
 
-				// windows daemons *always* require a valid windows base layer
-				if daemonOS == "windows" {
-					windowsBaseLayer := h.WindowsBaseLayer(t)
-					h.AssertNil(t, img.AddLayer(windowsBaseLayer))
-					defer os.Remove(windowsBaseLayer)
-				}
-
-				newLayerPath, err := h.CreateSingleFileLayerTar("/new-layer.txt", "new-layer", daemonOS)
-				h.AssertNil(t, err)
-				defer os.Remove(newLayerPath)
-
-				newLayerDiffID := h.FileDiffID(t, newLayerPath)
-
-				h.AssertNil(t, img.AddLayer(newLayerPath))
-
-				h.AssertNil(t, img.Save())
-				defer h.DockerRmi(dockerClient, repoName)
-
-				inspect, _, err := dockerClient.ImageInspectWithRaw(context.TODO(), repoName)
-				h.AssertNil(t, err)
-
-				h.AssertEq(t, newLayerDiffID, inspect.RootFS.Layers[len(inspect.RootFS.Layers)-1])
+				h.AssertError(t, img.AddLayer("/some/missing/layer.tar"), "AddLayer: open layer: /some/missing/layer.tar")
 			})
 		})
 
@@ -1017,6 +927,30 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 			h.AssertEq(t, prevLayer2SHA, reusedLayer2SHA)
 		})
 
+		it("reports the progress of reading the previous image", func() {
+			var updates []imgutil.Progress
+			img, err := local.NewImage(
+				repoName,
+				dockerClient,
+				local.WithPreviousImage(prevName),
+				local.FromBaseImage(runnableBaseImageName),
+				local.WithProgress(func(p imgutil.Progress) {
+					updates = append(updates, p)
+				}),
+			)
+			h.AssertNil(t, err)
+
+			h.AssertNil(t, img.ReuseLayer(prevLayer2SHA))
+
+			var completed []string
+			for _, update := range updates {
+				if update.Complete {
+					completed = append(completed, update.ID)
+				}
+			}
+			h.AssertContains(t, completed, "manifest.json")
+		})
+
 		it("does not download the old image if layers are directly above (performance)", func() {
 			img, err := local.NewImage(
 				repoName,
@@ -1041,6 +975,359 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 		})
 	})
 
+	when("#Rebase", func() {
+		var (
+			oldBaseLayerPath string
+			newBaseLayerPath string
+			appLayerPath     string
+		)
+
+		it.Before(func() {
+			var err error
+			oldBaseLayerPath, err = h.CreateSingleFileLayerTar("/base.txt", "old-base", daemonOS)
+			h.AssertNil(t, err)
+			newBaseLayerPath, err = h.CreateSingleFileLayerTar("/base.txt", "new-base", daemonOS)
+			h.AssertNil(t, err)
+			appLayerPath, err = h.CreateSingleFileLayerTar("/app.txt", "app", daemonOS)
+			h.AssertNil(t, err)
+		})
+
+		it.After(func() {
+			h.AssertNil(t, os.Remove(oldBaseLayerPath))
+			h.AssertNil(t, os.Remove(newBaseLayerPath))
+			h.AssertNil(t, os.Remove(appLayerPath))
+		})
+
+		it("rebases onto the layers of the given local base image, which needn't be saved", func() {
+			oldBaseName := newTestImageName()
+			oldBase, err := local.NewImage(oldBaseName, dockerClient)
+			h.AssertNil(t, err)
+			h.AssertNil(t, oldBase.AddLayer(oldBaseLayerPath))
+			h.AssertNil(t, oldBase.Save())
+
+			appName := newTestImageName()
+			img, err := local.NewImage(appName, dockerClient, local.FromBaseImage(oldBaseName))
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.AddLayer(appLayerPath))
+			h.AssertNil(t, img.Save())
+			defer h.DockerRmi(dockerClient, oldBaseName, appName)
+
+			newBase, err := local.NewImage(newTestImageName(), dockerClient)
+			h.AssertNil(t, err)
+			h.AssertNil(t, newBase.AddLayer(newBaseLayerPath))
+
+			img, err = local.NewImage(appName, dockerClient, local.FromBaseImage(appName))
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.Rebase(h.FileDiffID(t, oldBaseLayerPath), newBase))
+			h.AssertNil(t, img.Save())
+
+			inspect, _, err := dockerClient.ImageInspectWithRaw(context.TODO(), appName)
+			h.AssertNil(t, err)
+			h.AssertEq(t, inspect.RootFS.Layers, []string{h.FileDiffID(t, newBaseLayerPath), h.FileDiffID(t, appLayerPath)})
+		})
+
+		it("rebases onto a base image in a registry", func() {
+			oldBaseName := newTestImageName()
+			oldBase, err := local.NewImage(oldBaseName, dockerClient)
+			h.AssertNil(t, err)
+			h.AssertNil(t, oldBase.AddLayer(oldBaseLayerPath))
+			h.AssertNil(t, oldBase.Save())
+
+			appName := newTestImageName()
+			img, err := local.NewImage(appName, dockerClient, local.FromBaseImage(oldBaseName))
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.AddLayer(appLayerPath))
+			h.AssertNil(t, img.Save())
+			defer h.DockerRmi(dockerClient, oldBaseName, appName)
+
+			newBase, err := remote.NewImage(newTestImageName(), authn.DefaultKeychain)
+			h.AssertNil(t, err)
+			h.AssertNil(t, newBase.AddLayer(newBaseLayerPath))
+			h.AssertNil(t, newBase.Save())
+			newBase, err = remote.NewImage(newBase.Name(), authn.DefaultKeychain, remote.FromBaseImage(newBase.Name()))
+			h.AssertNil(t, err)
+
+			img, err = local.NewImage(appName, dockerClient, local.FromBaseImage(appName))
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.Rebase(h.FileDiffID(t, oldBaseLayerPath), newBase))
+			h.AssertNil(t, img.Save())
+
+			inspect, _, err := dockerClient.ImageInspectWithRaw(context.TODO(), appName)
+			h.AssertNil(t, err)
+			h.AssertEq(t, inspect.RootFS.Layers, []string{h.FileDiffID(t, newBaseLayerPath), h.FileDiffID(t, appLayerPath)})
+		})
+
+		it("can be the base a layout image is rebased onto", func() {
+			baseName := newTestImageName()
+			base, err := local.NewImage(baseName, dockerClient)
+			h.AssertNil(t, err)
+			h.AssertNil(t, base.AddLayer(newBaseLayerPath))
+			h.AssertNil(t, base.Save())
+			defer h.DockerRmi(dockerClient, baseName)
+
+			base, err = local.NewImage(baseName, dockerClient, local.FromBaseImage(baseName))
+			h.AssertNil(t, err)
+
+			tmpDir, err := ioutil.TempDir("", "imgutil.local.rebase.")
+			h.AssertNil(t, err)
+			defer os.RemoveAll(tmpDir)
+
+			appPath := filepath.Join(tmpDir, "app")
+			img, err := layout.NewImage(appPath)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.AddLayer(oldBaseLayerPath))
+			h.AssertNil(t, img.AddLayer(appLayerPath))
+			h.AssertNil(t, img.Save())
+
+			img, err = layout.NewImage(appPath, layout.FromBaseImage(appPath))
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.Rebase(h.FileDiffID(t, oldBaseLayerPath), base))
+			h.AssertNil(t, img.Save())
+
+			rebased, err := layout.NewImage(appPath, layout.FromBaseImage(appPath))
+			h.AssertNil(t, err)
+			diffIDs, err := rebased.DiffIDs()
+			h.AssertNil(t, err)
+			h.AssertEq(t, diffIDs, []string{h.FileDiffID(t, newBaseLayerPath), h.FileDiffID(t, appLayerPath)})
+		})
+
+		when("image exists", func() {
+			var (
+				repoName                              = newTestImageName()
+				oldBase, oldTopLayer, newBase, origID string
+				oldBaseLayer1DiffID                   string
+				oldBaseLayer2DiffID                   string
+				newBaseLayer1DiffID                   string
+				newBaseLayer2DiffID                   string
+				imgLayer1DiffID                       string
+				imgLayer2DiffID                       string
+				origNumLayers                         int
+			)
+
+			it.Before(func() {
+				// new base image
+				newBase = "pack-newbase-test-" + h.RandString(10)
+				newBaseImage, err := local.NewImage(newBase, dockerClient, local.FromBaseImage(runnableBaseImageName))
+				h.AssertNil(t, err)
+
+				newBaseLayer1Path, err := h.CreateSingleFileLayerTar("/new-base.txt", "new-base", daemonOS)
+				h.AssertNil(t, err)
+				defer os.Remove(newBaseLayer1Path)
+
+				newBaseLayer1DiffID = h.FileDiffID(t, newBaseLayer1Path)
+
+				newBaseLayer2Path, err := h.CreateSingleFileLayerTar("/otherfile.txt", "text-new-base", daemonOS)
+				h.AssertNil(t, err)
+				defer os.Remove(newBaseLayer2Path)
+
+				newBaseLayer2DiffID = h.FileDiffID(t, newBaseLayer2Path)
+
+				h.AssertNil(t, newBaseImage.AddLayer(newBaseLayer1Path))
+				h.AssertNil(t, newBaseImage.AddLayer(newBaseLayer2Path))
+
+				h.AssertNil(t, newBaseImage.Save())
+
+				// old base image
+				oldBase = "pack-oldbase-test-" + h.RandString(10)
+				oldBaseImage, err := local.NewImage(oldBase, dockerClient, local.FromBaseImage(runnableBaseImageName))
+				h.AssertNil(t, err)
+
+				oldBaseLayer1Path, err := h.CreateSingleFileLayerTar("/old-base.txt", "old-base", daemonOS)
+				h.AssertNil(t, err)
+				defer os.Remove(oldBaseLayer1Path)
+
+				oldBaseLayer1DiffID = h.FileDiffID(t, oldBaseLayer1Path)
+
+				oldBaseLayer2Path, err := h.CreateSingleFileLayerTar("/otherfile.txt", "text-old-base", daemonOS)
+				h.AssertNil(t, err)
+				defer os.Remove(oldBaseLayer2Path)
+
+				oldBaseLayer2DiffID = h.FileDiffID(t, oldBaseLayer2Path)
+
+				h.AssertNil(t, oldBaseImage.AddLayer(oldBaseLayer1Path))
+				h.AssertNil(t, oldBaseImage.AddLayer(oldBaseLayer2Path))
+
+				h.AssertNil(t, oldBaseImage.Save())
+
+				inspect, _, err := dockerClient.ImageInspectWithRaw(context.TODO(), oldBase)
+				h.AssertNil(t, err)
+				oldTopLayer = inspect.RootFS.Layers[len(inspect.RootFS.Layers)-1]
+
+				// original image
+				origImage, err := local.NewImage(repoName, dockerClient, local.FromBaseImage(oldBase))
+				h.AssertNil(t, err)
+
+				imgLayer1Path, err := h.CreateSingleFileLayerTar("/myimage.txt", "text-from-image", daemonOS)
+				h.AssertNil(t, err)
+				defer os.Remove(imgLayer1Path)
+
+				imgLayer1DiffID = h.FileDiffID(t, imgLayer1Path)
+
+				imgLayer2Path, err := h.CreateSingleFileLayerTar("/myimage2.txt", "text-from-image", daemonOS)
+				h.AssertNil(t, err)
+				defer os.Remove(imgLayer2Path)
+
+				imgLayer2DiffID = h.FileDiffID(t, imgLayer2Path)
+
+				h.AssertNil(t, origImage.AddLayer(imgLayer1Path))
+				h.AssertNil(t, origImage.AddLayer(imgLayer2Path))
+
+				h.AssertNil(t, origImage.Save())
+
+				inspect, _, err = dockerClient.ImageInspectWithRaw(context.TODO(), repoName)
+				h.AssertNil(t, err)
+				origNumLayers = len(inspect.RootFS.Layers)
+				origID = inspect.ID
+			})
+
+			it.After(func() {
+				h.AssertNil(t, h.DockerRmi(dockerClient, repoName, oldBase, newBase, origID))
+			})
+
+			it("switches the base", func() {
+				// Before
+				beforeInspect, _, err := dockerClient.ImageInspectWithRaw(context.TODO(), repoName)
+				h.AssertNil(t, err)
+
+				beforeOldBaseLayer1DiffID := beforeInspect.RootFS.Layers[len(beforeInspect.RootFS.Layers)-4]
+				h.AssertEq(t, oldBaseLayer1DiffID, beforeOldBaseLayer1DiffID)
+
+				beforeOldBaseLayer2DiffID := beforeInspect.RootFS.Layers[len(beforeInspect.RootFS.Layers)-3]
+				h.AssertEq(t, oldBaseLayer2DiffID, beforeOldBaseLayer2DiffID)
+
+				beforeLayer3DiffID := beforeInspect.RootFS.Layers[len(beforeInspect.RootFS.Layers)-2]
+				h.AssertEq(t, imgLayer1DiffID, beforeLayer3DiffID)
+
+				beforeLayer4DiffID := beforeInspect.RootFS.Layers[len(beforeInspect.RootFS.Layers)-1]
+				h.AssertEq(t, imgLayer2DiffID, beforeLayer4DiffID)
+
+				// Run rebase
+				img, err := local.NewImage(repoName, dockerClient, local.FromBaseImage(repoName))
+				h.AssertNil(t, err)
+				newBaseImg, err := local.NewImage(newBase, dockerClient, local.FromBaseImage(newBase))
+				h.AssertNil(t, err)
+				err = img.Rebase(oldTopLayer, newBaseImg)
+				h.AssertNil(t, err)
+
+				h.AssertNil(t, img.Save())
+
+				// After
+				afterInspect, _, err := dockerClient.ImageInspectWithRaw(context.TODO(), repoName)
+				h.AssertNil(t, err)
+
+				numLayers := len(afterInspect.RootFS.Layers)
+				h.AssertEq(t, numLayers, origNumLayers)
+
+				afterLayer1DiffID := afterInspect.RootFS.Layers[len(afterInspect.RootFS.Layers)-4]
+				h.AssertEq(t, newBaseLayer1DiffID, afterLayer1DiffID)
+
+				afterLayer2DiffID := afterInspect.RootFS.Layers[len(afterInspect.RootFS.Layers)-3]
+				h.AssertEq(t, newBaseLayer2DiffID, afterLayer2DiffID)
+
+				afterLayer3DiffID := afterInspect.RootFS.Layers[len(afterInspect.RootFS.Layers)-2]
+				h.AssertEq(t, imgLayer1DiffID, afterLayer3DiffID)
+
+				afterLayer4DiffID := afterInspect.RootFS.Layers[len(afterInspect.RootFS.Layers)-1]
+				h.AssertEq(t, imgLayer2DiffID, afterLayer4DiffID)
+			})
+		})
+	})
+
+	when("#Cleanup", func() {
+		var (
+			prevName   string
+			repoName   string
+			scratchDir string
+			layerPath  string
+		)
+
+		it.Before(func() {
+			prevName = newTestImageName()
+			repoName = newTestImageName()
+
+			var err error
+			scratchDir, err = ioutil.TempDir("", "imgutil.local.scratch.")
+			h.AssertNil(t, err)
+
+			prevImage, err := local.NewImage(prevName, dockerClient, local.FromBaseImage(runnableBaseImageName))
+			h.AssertNil(t, err)
+			layerPath, err = h.CreateSingleFileLayerTar("/layer.txt", "old-layer", daemonOS)
+			h.AssertNil(t, err)
+			h.AssertNil(t, prevImage.AddLayer(layerPath))
+			h.AssertNil(t, prevImage.Save())
+		})
+
+		it.After(func() {
+			h.AssertNil(t, h.DockerRmi(dockerClient, repoName, prevName))
+			h.AssertNil(t, os.RemoveAll(scratchDir))
+			h.AssertNil(t, os.Remove(layerPath))
+		})
+
+		it("removes the archive of the previous image from the scratch directory", func() {
+			img, err := local.NewImage(repoName, dockerClient,
+				local.FromBaseImage(runnableBaseImageName),
+				local.WithPreviousImage(prevName),
+				local.WithScratchDir(scratchDir),
+			)
+			h.AssertNil(t, err)
+
+			h.AssertNil(t, img.ReuseLayer(h.FileDiffID(t, layerPath)))
+			h.AssertNil(t, img.Save())
+
+			entries, err := ioutil.ReadDir(scratchDir)
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(entries), 1)
+
+			h.AssertNil(t, img.Cleanup())
+
+			entries, err = ioutil.ReadDir(scratchDir)
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(entries), 0)
+		})
+
+		it("leaves nothing behind when reading the previous image fails", func() {
+			img, err := local.NewImage(repoName, dockerClient,
+				local.WithPreviousImage(newTestImageName()),
+				local.WithScratchDir(scratchDir),
+			)
+			h.AssertNil(t, err)
+
+			h.AssertNotEq(t, img.ReuseLayer(h.FileDiffID(t, layerPath)), nil)
+
+			entries, err := ioutil.ReadDir(scratchDir)
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(entries), 0)
+		})
+
+		it("returns an error for a missing scratch directory", func() {
+			_, err := local.NewImage(repoName, dockerClient, local.WithScratchDir(filepath.Join(scratchDir, "missing")))
+			h.AssertError(t, err, "invalid scratch directory")
+		})
+	})
+
+	when("#WithProgress", func() {
+		it("reports the progress of loading layers into the daemon", func() {
+			repoName := newTestImageName()
+			defer h.DockerRmi(dockerClient, repoName)
+
+			var updates []imgutil.Progress
+			img, err := local.NewImage(repoName, dockerClient, local.WithProgress(func(p imgutil.Progress) {
+				updates = append(updates, p)
+			}))
+			h.AssertNil(t, err)
+
+			layerPath, err := h.CreateSingleFileLayerTar("/new-layer.txt", "new-layer-"+h.RandString(10), daemonOS)
+			h.AssertNil(t, err)
+			defer os.Remove(layerPath)
+
+			h.AssertNil(t, img.AddLayer(layerPath))
+			h.AssertNil(t, img.Save())
+
+			h.AssertEq(t, len(updates) > 0, true)
+			h.AssertEq(t, updates[len(updates)-1].Complete, true)
+		})
+	})
+
 	when("#Save", func() {
 		when("image is valid", func() {
 			var (
@@ -1132,15 +1419,6 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 					h.AssertNil(t, h.DockerRmi(dockerClient, additionalRepoNames...))
 				})
 
-				it("saves to multiple names", func() {
-					h.AssertNil(t, img.Save(additionalRepoNames...))
-
-					for _, n := range successfulRepoNames {
-						_, _, err := dockerClient.ImageInspectWithRaw(context.TODO(), n)
-						h.AssertNil(t, err)
-					}
-				})
-
 				when("a single image name fails", func() {
 					it("returns results with errors for those that failed", func() {
 						failingName := newTestImageName() + ":🧨"
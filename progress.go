@@ -0,0 +1,51 @@
+package imgutil
+
+import (
+	"io"
+)
+
+// Progress reports how much of a blob has been transferred.
+type Progress struct {
+	// ID identifies the blob, e.g. the digest of a layer or its path in an image archive.
+	ID string
+	// Transferred is the number of bytes transferred so far.
+	Transferred int64
+	// Total is the size of the blob in bytes, or -1 when it isn't known.
+	Total int64
+	// Complete is set on the last update reported for the blob.
+	Complete bool
+}
+
+// ProgressFunc receives progress updates. It may be called concurrently for different blobs.
+type ProgressFunc func(Progress)
+
+// NewProgressReader returns a reader that reports the bytes read from r to fn as the transfer of the blob id.
+// The transfer is complete once r returns io.EOF.
+func NewProgressReader(r io.Reader, id string, total int64, fn ProgressFunc) io.Reader {
+	return &progressReader{
+		reader:   r,
+		fn:       fn,
+		progress: Progress{ID: id, Total: total},
+	}
+}
+
+type progressReader struct {
+	reader   io.Reader
+	fn       ProgressFunc
+	progress Progress
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if r.progress.Complete {
+		return n, err
+	}
+	r.progress.Transferred += int64(n)
+	if err == io.EOF {
+		r.progress.Complete = true
+	}
+	if n > 0 || r.progress.Complete {
+		r.fn(r.progress)
+	}
+	return n, err
+}
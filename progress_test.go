@@ -0,0 +1,54 @@
+package imgutil_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"testing/iotest"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestProgress(t *testing.T) {
+	spec.Run(t, "Progress", testProgress, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testProgress(t *testing.T, when spec.G, it spec.S) {
+	when("#NewProgressReader", func() {
+		it("reports the bytes read and completes at EOF", func() {
+			var updates []imgutil.Progress
+			r := imgutil.NewProgressReader(iotest.OneByteReader(bytes.NewReader([]byte("abc"))), "some-blob", 3, func(p imgutil.Progress) {
+				updates = append(updates, p)
+			})
+
+			contents, err := ioutil.ReadAll(r)
+			h.AssertNil(t, err)
+			h.AssertEq(t, string(contents), "abc")
+
+			h.AssertEq(t, updates, []imgutil.Progress{
+				{ID: "some-blob", Transferred: 1, Total: 3},
+				{ID: "some-blob", Transferred: 2, Total: 3},
+				{ID: "some-blob", Transferred: 3, Total: 3},
+				{ID: "some-blob", Transferred: 3, Total: 3, Complete: true},
+			})
+		})
+
+		it("reports completion once", func() {
+			var updates []imgutil.Progress
+			r := imgutil.NewProgressReader(bytes.NewReader(nil), "some-blob", -1, func(p imgutil.Progress) {
+				updates = append(updates, p)
+			})
+
+			_, err := ioutil.ReadAll(r)
+			h.AssertNil(t, err)
+			_, err = ioutil.ReadAll(r)
+			h.AssertNil(t, err)
+
+			h.AssertEq(t, updates, []imgutil.Progress{{ID: "some-blob", Total: -1, Complete: true}})
+		})
+	})
+}
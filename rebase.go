@@ -0,0 +1,152 @@
+package imgutil
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RebaseOption is a check made by Rebase before it switches the base of an image. Without options, Rebase accepts
+// any new base.
+type RebaseOption func(*rebasePolicy)
+
+type rebasePolicy struct {
+	platform bool
+	labels   []string
+}
+
+// MatchPlatform makes Rebase require the OS, OS version and architecture of the new base to equal those of the image,
+// which are inherited from its current base. OS versions are compared up to the build number, e.g. 10.0.17763, so that
+// a Windows image can be rebased onto a new base that only updates its patch level.
+func MatchPlatform() RebaseOption {
+	return func(p *rebasePolicy) {
+		p.platform = true
+	}
+}
+
+// MatchLabels makes Rebase require the values of the labels with the given keys, e.g. a stack id label, to be equal
+// on the image and the new base. A label that is missing on both is equal.
+func MatchLabels(keys ...string) RebaseOption {
+	return func(p *rebasePolicy) {
+		p.labels = append(p.labels, keys...)
+	}
+}
+
+// Mismatch is a property that differs between an image and the new base it is rebased onto. Kind is
+// ErrPlatformMismatch for the OS, OS version and architecture, and ErrLabelMismatch for labels, whose key is Name.
+type Mismatch struct {
+	Kind    error
+	Name    string
+	Image   string
+	NewBase string
+}
+
+// RebaseMismatchError is returned by Rebase when the new base doesn't match the image as required by its options.
+// It lists every mismatch, and matches the kind of each of them with errors.Is.
+type RebaseMismatchError struct {
+	ImageName   string
+	NewBaseName string
+	Mismatches  []Mismatch
+}
+
+func (e *RebaseMismatchError) Error() string {
+	var details []string
+	for _, m := range e.Mismatches {
+		name := m.Name
+		if m.Kind == ErrLabelMismatch {
+			name = fmt.Sprintf("label '%s'", m.Name)
+		}
+		details = append(details, fmt.Sprintf("%s is '%s' but new base has '%s'", name, m.Image, m.NewBase))
+	}
+	return fmt.Sprintf("cannot rebase image '%s' onto '%s': %s", e.ImageName, e.NewBaseName, strings.Join(details, ", "))
+}
+
+// Is reports whether target is the kind of any of the mismatches.
+func (e *RebaseMismatchError) Is(target error) bool {
+	for _, m := range e.Mismatches {
+		if m.Kind == target {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateRebase makes the checks of ops, returning a *RebaseMismatchError if newBase can't be the new base of img.
+// It is called by Rebase, and can be used to validate a rebase before making it.
+func ValidateRebase(img, newBase Image, ops ...RebaseOption) error {
+	var policy rebasePolicy
+	for _, op := range ops {
+		op(&policy)
+	}
+
+	var mismatches []Mismatch
+	if policy.platform {
+		for _, property := range []struct {
+			name  string
+			value func(Image) (string, error)
+			equal func(a, b string) bool
+		}{
+			{"os", Image.OS, equal},
+			{"os version", Image.OSVersion, sameOSBuild},
+			{"architecture", Image.Architecture, equal},
+		} {
+			m, err := compare(img, newBase, property.value, property.equal)
+			if err != nil {
+				return err
+			}
+			if m != nil {
+				m.Kind, m.Name = ErrPlatformMismatch, property.name
+				mismatches = append(mismatches, *m)
+			}
+		}
+	}
+	for _, key := range policy.labels {
+		m, err := compare(img, newBase, func(i Image) (string, error) {
+			return i.Label(key)
+		}, equal)
+		if err != nil {
+			return err
+		}
+		if m != nil {
+			m.Kind, m.Name = ErrLabelMismatch, key
+			mismatches = append(mismatches, *m)
+		}
+	}
+
+	if len(mismatches) > 0 {
+		return &RebaseMismatchError{ImageName: img.Name(), NewBaseName: newBase.Name(), Mismatches: mismatches}
+	}
+	return nil
+}
+
+// compare returns the values of a property of img and newBase if they aren't equal.
+func compare(img, newBase Image, value func(Image) (string, error), equal func(a, b string) bool) (*Mismatch, error) {
+	imgValue, err := value(img)
+	if err != nil {
+		return nil, err
+	}
+	newBaseValue, err := value(newBase)
+	if err != nil {
+		return nil, err
+	}
+	if equal(imgValue, newBaseValue) {
+		return nil, nil
+	}
+	return &Mismatch{Image: imgValue, NewBase: newBaseValue}, nil
+}
+
+func equal(a, b string) bool {
+	return a == b
+}
+
+// sameOSBuild reports whether OS versions a and b are equal up to the build number, i.e. their first three parts.
+func sameOSBuild(a, b string) bool {
+	return osBuild(a) == osBuild(b)
+}
+
+func osBuild(osVersion string) string {
+	parts := strings.SplitN(osVersion, ".", 4)
+	if len(parts) > 3 {
+		parts = parts[:3]
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,91 @@
+package imgutil_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/fakes"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestRebase(t *testing.T) {
+	spec.Run(t, "Rebase", testRebase, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testRebase(t *testing.T, when spec.G, it spec.S) {
+	when("#ValidateRebase", func() {
+		var img, newBase *fakes.Image
+
+		it.Before(func() {
+			img = fakes.NewImage("some-image", "", nil)
+			img.SetPlatform("linux", "", "amd64")
+			h.AssertNil(t, img.SetLabel("io.buildpacks.stack.id", "some-stack"))
+
+			newBase = fakes.NewImage("some-new-base", "", nil)
+			newBase.SetPlatform("windows", "10.0.17763.1040", "amd64")
+			h.AssertNil(t, newBase.SetLabel("io.buildpacks.stack.id", "other-stack"))
+		})
+
+		it("accepts any new base without options", func() {
+			h.AssertNil(t, imgutil.ValidateRebase(img, newBase))
+		})
+
+		it("returns the platform mismatches", func() {
+			err := imgutil.ValidateRebase(img, newBase, imgutil.MatchPlatform())
+
+			h.AssertError(t, err, "cannot rebase image 'some-image' onto 'some-new-base': os is 'linux' but new base has 'windows', os version is '' but new base has '10.0.17763.1040'")
+			h.AssertEq(t, errors.Is(err, imgutil.ErrPlatformMismatch), true)
+			h.AssertEq(t, errors.Is(err, imgutil.ErrLabelMismatch), false)
+
+			var mismatchErr *imgutil.RebaseMismatchError
+			h.AssertEq(t, errors.As(err, &mismatchErr), true)
+			h.AssertEq(t, len(mismatchErr.Mismatches), 2)
+			h.AssertEq(t, mismatchErr.Mismatches[0].Name, "os")
+			h.AssertEq(t, mismatchErr.Mismatches[0].Image, "linux")
+			h.AssertEq(t, mismatchErr.Mismatches[0].NewBase, "windows")
+		})
+
+		it("returns the label mismatches", func() {
+			h.AssertNil(t, img.SetLabel("some-label", "some-value"))
+			h.AssertNil(t, newBase.SetLabel("some-label", "some-value"))
+
+			err := imgutil.ValidateRebase(img, newBase, imgutil.MatchLabels("io.buildpacks.stack.id", "some-label", "some-missing-label"))
+
+			h.AssertError(t, err, "label 'io.buildpacks.stack.id' is 'some-stack' but new base has 'other-stack'")
+			h.AssertEq(t, errors.Is(err, imgutil.ErrLabelMismatch), true)
+			h.AssertEq(t, errors.Is(err, imgutil.ErrPlatformMismatch), false)
+
+			var mismatchErr *imgutil.RebaseMismatchError
+			h.AssertEq(t, errors.As(err, &mismatchErr), true)
+			h.AssertEq(t, len(mismatchErr.Mismatches), 1)
+		})
+
+		it("accepts a new base whose os version only differs in patch level", func() {
+			img.SetPlatform("windows", "10.0.17763.1234", "amd64")
+			newBase.SetPlatform("windows", "10.0.17763.1339", "amd64")
+
+			h.AssertNil(t, imgutil.ValidateRebase(img, newBase, imgutil.MatchPlatform()))
+		})
+
+		it("returns a mismatch for a new base with another os build", func() {
+			img.SetPlatform("windows", "10.0.17763.1234", "amd64")
+			newBase.SetPlatform("windows", "10.0.18363.1234", "amd64")
+
+			err := imgutil.ValidateRebase(img, newBase, imgutil.MatchPlatform())
+
+			h.AssertError(t, err, "os version is '10.0.17763.1234' but new base has '10.0.18363.1234'")
+			h.AssertEq(t, errors.Is(err, imgutil.ErrPlatformMismatch), true)
+		})
+
+		it("accepts a matching new base", func() {
+			newBase.SetPlatform("linux", "", "amd64")
+			h.AssertNil(t, newBase.SetLabel("io.buildpacks.stack.id", "some-stack"))
+
+			h.AssertNil(t, imgutil.ValidateRebase(img, newBase, imgutil.MatchPlatform(), imgutil.MatchLabels("io.buildpacks.stack.id")))
+		})
+	})
+}
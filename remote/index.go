@@ -0,0 +1,170 @@
+package remote
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/pkg/errors"
+
+	"github.com/buildpacks/imgutil"
+)
+
+type ImageIndex struct {
+	repoName  string
+	mediaType types.MediaType
+	adds      []mutate.IndexAddendum
+	imageOps  []ImageOption
+	// registry makes the registry requests of the index, as configured by imageOps
+	registry *Image
+}
+
+type IndexOption func(*ImageIndex) (*ImageIndex, error)
+
+// WithIndexMediaType sets the media type of the saved index, defaults to a Docker manifest list.
+func WithIndexMediaType(mediaType types.MediaType) IndexOption {
+	return func(i *ImageIndex) (*ImageIndex, error) {
+		if !isIndex(mediaType) {
+			return nil, fmt.Errorf("unsupported index media type '%s'", mediaType)
+		}
+		i.mediaType = mediaType
+		return i, nil
+	}
+}
+
+// WithImageOptions makes the index use the context, transport, retry policy, insecure registries, CA bundles and
+// client certificates set by ops for its registry requests, so that it reaches registries the same way as the
+// images added to it.
+func WithImageOptions(ops ...ImageOption) IndexOption {
+	return func(i *ImageIndex) (*ImageIndex, error) {
+		i.imageOps = append(i.imageOps, ops...)
+		return i, nil
+	}
+}
+
+func NewIndex(repoName string, keychain authn.Keychain, ops ...IndexOption) (imgutil.ImageIndex, error) {
+	var err error
+
+	ri := &ImageIndex{
+		repoName:  repoName,
+		mediaType: types.DockerManifestList,
+	}
+
+	for _, op := range ops {
+		ri, err = op(ri)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if ri.registry, err = newImage(repoName, keychain, ri.imageOps); err != nil {
+		return nil, err
+	}
+
+	return ri, nil
+}
+
+func (i *ImageIndex) Name() string {
+	return i.repoName
+}
+
+// Add adds image to the index. Remote images are added as they are, any other image must already be saved to
+// a registry under `Name()`. The platform of the image is recorded in the index, including the variant of remote
+// images, e.g. "v7" for linux/arm/v7.
+func (i *ImageIndex) Add(image imgutil.Image) error {
+	platform, err := platformForImage(image)
+	if err != nil {
+		return err
+	}
+
+	for _, add := range i.adds {
+		if platformString(*add.Descriptor.Platform) == platformString(*platform) {
+			return fmt.Errorf("index '%s' already contains an image for platform '%s'", i.repoName, platformString(*platform))
+		}
+	}
+
+	v1Image, err := i.v1ImageFor(image)
+	if err != nil {
+		return err
+	}
+
+	i.adds = append(i.adds, mutate.IndexAddendum{
+		Add: v1Image,
+		Descriptor: v1.Descriptor{
+			Platform: platform,
+		},
+	})
+	return nil
+}
+
+func (i *ImageIndex) v1ImageFor(image imgutil.Image) (v1.Image, error) {
+	if remoteImage, ok := image.(*Image); ok {
+		return remoteImage.V1Image, nil
+	}
+
+	v1Image, _, err := i.registry.readV1Image(image.Name())
+	if err != nil {
+		return nil, errors.Wrapf(err, "image '%s' must be saved to a registry before being added to an index", image.Name())
+	}
+	return v1Image, nil
+}
+
+func platformForImage(image imgutil.Image) (*v1.Platform, error) {
+	os, err := image.OS()
+	if err != nil {
+		return nil, err
+	}
+	osVersion, err := image.OSVersion()
+	if err != nil {
+		return nil, err
+	}
+	arch, err := image.Architecture()
+	if err != nil {
+		return nil, err
+	}
+	platform := &v1.Platform{
+		OS:           os,
+		OSVersion:    osVersion,
+		Architecture: arch,
+	}
+	if remoteImage, ok := image.(*Image); ok {
+		platform.Variant = remoteImage.variant
+	}
+	return platform, nil
+}
+
+func (i *ImageIndex) Save(additionalNames ...string) error {
+	if len(i.adds) == 0 {
+		return fmt.Errorf("index '%s' has no images", i.repoName)
+	}
+
+	index := mutate.IndexMediaType(mutate.AppendManifests(empty.Index, i.adds...), i.mediaType)
+
+	var diagnostics []imgutil.SaveDiagnostic
+	for _, n := range append([]string{i.repoName}, additionalNames...) {
+		if err := i.doSave(index, n); err != nil {
+			diagnostics = append(diagnostics, imgutil.SaveDiagnostic{ImageName: n, Cause: err})
+		}
+	}
+	if len(diagnostics) > 0 {
+		return imgutil.SaveError{Errors: diagnostics}
+	}
+
+	return nil
+}
+
+func (i *ImageIndex) doSave(index v1.ImageIndex, indexName string) error {
+	ref, auth, err := i.registry.referenceForRepoName(indexName)
+	if err != nil {
+		return err
+	}
+	_, err = i.registry.write(i.registry.ctx, func(rt http.RoundTripper) error {
+		return remote.WriteIndex(ref, index, remote.WithAuth(auth), remote.WithTransport(rt))
+	})
+	return err
+}
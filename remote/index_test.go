@@ -0,0 +1,255 @@
+package remote_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	ggcrremote "github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/sclevine/spec"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/fakes"
+	"github.com/buildpacks/imgutil/remote"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func testImageIndex(t *testing.T, when spec.G, it spec.S) {
+	var (
+		indexName  string
+		layerPath  string
+		linuxImage imgutil.Image
+		armImage   imgutil.Image
+	)
+
+	it.Before(func() {
+		var err error
+		indexName = newTestImageName()
+
+		// remote images are added as they are, so their layers are read again when the index is saved
+		layerPath, err = h.CreateSingleFileLayerTar("/some-file.txt", "some-contents", "linux")
+		h.AssertNil(t, err)
+
+		linuxImage, err = remote.NewImage(newTestImageName(), authn.DefaultKeychain)
+		h.AssertNil(t, err)
+		h.AssertNil(t, linuxImage.AddLayer(layerPath))
+		h.AssertNil(t, linuxImage.Save())
+
+		armImage, err = remote.NewImage(newTestImageName(), authn.DefaultKeychain, remote.WithPlatform("linux", "arm64", "", ""))
+		h.AssertNil(t, err)
+		h.AssertNil(t, armImage.AddLayer(layerPath))
+		h.AssertNil(t, armImage.Save())
+	})
+
+	it.After(func() {
+		h.AssertNil(t, os.Remove(layerPath))
+	})
+
+	when("#Save", func() {
+		it("pushes a manifest list describing each image's platform", func() {
+			index, err := remote.NewIndex(indexName, authn.DefaultKeychain)
+			h.AssertNil(t, err)
+
+			h.AssertNil(t, index.Add(linuxImage))
+			h.AssertNil(t, index.Add(armImage))
+			h.AssertNil(t, index.Save())
+
+			manifest := fetchIndexManifest(t, indexName)
+			h.AssertEq(t, fetchMediaType(t, indexName), types.DockerManifestList)
+			h.AssertEq(t, len(manifest.Manifests), 2)
+			h.AssertEq(t, manifest.Manifests[0].Platform.Architecture, "amd64")
+			h.AssertEq(t, manifest.Manifests[1].Platform.Architecture, "arm64")
+
+			linuxID, err := linuxImage.Identifier()
+			h.AssertNil(t, err)
+			h.AssertEq(t, linuxID.String(), linuxImage.Name()+"@"+manifest.Manifests[0].Digest.String())
+		})
+
+		it("can be used as a base image for each platform", func() {
+			index, err := remote.NewIndex(indexName, authn.DefaultKeychain)
+			h.AssertNil(t, err)
+
+			h.AssertNil(t, index.Add(linuxImage))
+			h.AssertNil(t, index.Add(armImage))
+			h.AssertNil(t, index.Save())
+
+			img, err := remote.NewImage(newTestImageName(), authn.DefaultKeychain,
+				remote.FromBaseImage(indexName),
+				remote.WithPlatform("linux", "arm64", "", ""),
+			)
+			h.AssertNil(t, err)
+
+			arch, err := img.Architecture()
+			h.AssertNil(t, err)
+			h.AssertEq(t, arch, "arm64")
+		})
+
+		it("saves additional names", func() {
+			additionalName := newTestImageName()
+
+			index, err := remote.NewIndex(indexName, authn.DefaultKeychain)
+			h.AssertNil(t, err)
+
+			h.AssertNil(t, index.Add(linuxImage))
+			h.AssertNil(t, index.Save(additionalName))
+
+			h.AssertEq(t, len(fetchIndexManifest(t, indexName).Manifests), 1)
+			h.AssertEq(t, len(fetchIndexManifest(t, additionalName).Manifests), 1)
+		})
+
+		when("#WithIndexMediaType", func() {
+			it("pushes an OCI image index", func() {
+				index, err := remote.NewIndex(indexName, authn.DefaultKeychain, remote.WithIndexMediaType(types.OCIImageIndex))
+				h.AssertNil(t, err)
+
+				h.AssertNil(t, index.Add(linuxImage))
+				h.AssertNil(t, index.Save())
+
+				h.AssertEq(t, fetchMediaType(t, indexName), types.OCIImageIndex)
+			})
+
+			it("rejects media types that aren't indexes", func() {
+				_, err := remote.NewIndex(indexName, authn.DefaultKeychain, remote.WithIndexMediaType(types.DockerManifestSchema2))
+				h.AssertError(t, err, fmt.Sprintf("unsupported index media type '%s'", types.DockerManifestSchema2))
+			})
+		})
+
+		it("records the variant of the images", func() {
+			armV7Image, err := remote.NewImage(newTestImageName(), authn.DefaultKeychain, remote.WithPlatform("linux", "arm", "v7", ""))
+			h.AssertNil(t, err)
+			h.AssertNil(t, armV7Image.Save())
+
+			index, err := remote.NewIndex(indexName, authn.DefaultKeychain)
+			h.AssertNil(t, err)
+
+			h.AssertNil(t, index.Add(armV7Image))
+			h.AssertNil(t, index.Save())
+
+			platform := fetchIndexManifest(t, indexName).Manifests[0].Platform
+			h.AssertEq(t, platformString(platform), "linux/arm/v7")
+		})
+
+		when("#WithImageOptions", func() {
+			it("makes registry requests as the image options ask", func() {
+				transport := &flakyTransport{status: http.StatusServiceUnavailable}
+				policy := remote.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}
+
+				index, err := remote.NewIndex(indexName, authn.DefaultKeychain, remote.WithImageOptions(remote.WithTransport(transport), remote.WithRetry(policy)))
+				h.AssertNil(t, err)
+
+				h.AssertNil(t, index.Add(fakes.NewImage(linuxImage.Name(), "", nil)))
+				h.AssertNil(t, index.Save())
+
+				indexRepo := strings.TrimPrefix(indexName, registryHost+"/")
+				h.AssertEq(t, transport.failedRequest("GET /v2/"+strings.TrimPrefix(linuxImage.Name(), registryHost+"/")), true)
+				h.AssertEq(t, transport.failedRequest("PUT /v2/"+indexRepo+"/manifests/latest"), true)
+			})
+
+			it("uses the context of the image options", func() {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+
+				index, err := remote.NewIndex(indexName, authn.DefaultKeychain, remote.WithImageOptions(remote.WithContext(ctx)))
+				h.AssertNil(t, err)
+
+				h.AssertNil(t, index.Add(linuxImage))
+				h.AssertError(t, index.Save(), "context canceled")
+			})
+		})
+
+		when("the index has no images", func() {
+			it("returns an error", func() {
+				index, err := remote.NewIndex(indexName, authn.DefaultKeychain)
+				h.AssertNil(t, err)
+
+				h.AssertError(t, index.Save(), "has no images")
+			})
+		})
+	})
+
+	when("#Add", func() {
+		it("adds a remote image as it is, without reading it from the registry", func() {
+			unsavedImage, err := remote.NewImage(newTestImageName(), authn.DefaultKeychain)
+			h.AssertNil(t, err)
+			h.AssertNil(t, unsavedImage.AddLayer(layerPath))
+
+			index, err := remote.NewIndex(indexName, authn.DefaultKeychain)
+			h.AssertNil(t, err)
+
+			h.AssertNil(t, index.Add(unsavedImage))
+			h.AssertNil(t, index.Save())
+
+			identifier, err := unsavedImage.Identifier()
+			h.AssertNil(t, err)
+			h.AssertEq(t, unsavedImage.Name()+"@"+fetchIndexManifest(t, indexName).Manifests[0].Digest.String(), identifier.String())
+		})
+
+		it("adds an image saved by another backend by name", func() {
+			fakeImage := fakes.NewImage(linuxImage.Name(), "", nil)
+
+			index, err := remote.NewIndex(indexName, authn.DefaultKeychain)
+			h.AssertNil(t, err)
+
+			h.AssertNil(t, index.Add(fakeImage))
+			h.AssertNil(t, index.Save())
+
+			h.AssertEq(t, len(fetchIndexManifest(t, indexName).Manifests), 1)
+		})
+
+		it("returns an error if the image isn't saved to a registry", func() {
+			fakeImage := fakes.NewImage(newTestImageName(), "", nil)
+
+			index, err := remote.NewIndex(indexName, authn.DefaultKeychain)
+			h.AssertNil(t, err)
+
+			h.AssertError(t, index.Add(fakeImage), "must be saved to a registry before being added to an index")
+		})
+
+		it("returns an error when the platform is already present", func() {
+			index, err := remote.NewIndex(indexName, authn.DefaultKeychain)
+			h.AssertNil(t, err)
+
+			h.AssertNil(t, index.Add(linuxImage))
+			h.AssertError(t, index.Add(linuxImage), "already contains an image for platform 'linux/amd64'")
+		})
+	})
+}
+
+func platformString(platform *v1.Platform) string {
+	return platform.OS + "/" + platform.Architecture + "/" + platform.Variant
+}
+
+func fetchIndexManifest(t *testing.T, repoName string) *v1.IndexManifest {
+	t.Helper()
+
+	ref, err := name.ParseReference(repoName, name.WeakValidation)
+	h.AssertNil(t, err)
+
+	index, err := ggcrremote.Index(ref, ggcrremote.WithTransport(http.DefaultTransport))
+	h.AssertNil(t, err)
+
+	manifest, err := index.IndexManifest()
+	h.AssertNil(t, err)
+
+	return manifest
+}
+
+func fetchMediaType(t *testing.T, repoName string) types.MediaType {
+	t.Helper()
+
+	ref, err := name.ParseReference(repoName, name.WeakValidation)
+	h.AssertNil(t, err)
+
+	desc, err := ggcrremote.Get(ref, ggcrremote.WithTransport(http.DefaultTransport))
+	h.AssertNil(t, err)
+
+	return desc.MediaType
+}
@@ -1,78 +1,274 @@
 package remote
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
-	"github.com/google/go-containerregistry/pkg/v1/empty"
-	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
-	"github.com/google/go-containerregistry/pkg/v1/tarball"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/pkg/errors"
 
 	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/internal/v1image"
 )
 
 type Image struct {
-	keychain   authn.Keychain
-	repoName   string
-	image      v1.Image
-	prevLayers []v1.Layer
+	v1image.Image
+	ctx           context.Context
+	keychain      authn.Keychain
+	repoName      string
+	platform      *v1.Platform
+	variant       string
+	baseImageName string
+	prevImageName string
+
+	saveConcurrency int
+	progress        imgutil.ProgressFunc
+	transport       http.RoundTripper
+	retryPolicy     *RetryPolicy
+
+	insecureRegistries map[string]bool
+	caBundles          [][]byte
+	clientCerts        []tls.Certificate
+
+	mirrors         map[string][]string
+	baseImageSource string
+	prevImageSource string
+
+	baseImageStrict bool
+	prevImageStrict bool
 }
 
 type ImageOption func(*Image) (*Image, error)
 
-func WithPreviousImage(imageName string) ImageOption {
+// ResolveOption configures how FromBaseImage and WithPreviousImage resolve their image.
+type ResolveOption func(*resolveOptions)
+
+type resolveOptions struct {
+	strict bool
+}
+
+// Strict makes NewImage fail when the image doesn't exist or the registry refuses access to it, instead of
+// starting from an empty image.
+func Strict() ResolveOption {
+	return func(o *resolveOptions) {
+		o.strict = true
+	}
+}
+
+func WithPreviousImage(imageName string, ops ...ResolveOption) ImageOption {
 	return func(r *Image) (*Image, error) {
-		var err error
+		var o resolveOptions
+		for _, op := range ops {
+			op(&o)
+		}
+		r.prevImageName = imageName
+		r.prevImageStrict = o.strict
+		return r, nil
+	}
+}
 
-		prevImage, err := newV1Image(r.keychain, imageName)
-		if err != nil {
-			return nil, err
+func FromBaseImage(imageName string, ops ...ResolveOption) ImageOption {
+	return func(r *Image) (*Image, error) {
+		var o resolveOptions
+		for _, op := range ops {
+			op(&o)
 		}
+		r.baseImageName = imageName
+		r.baseImageStrict = o.strict
+		return r, nil
+	}
+}
 
-		prevLayers, err := prevImage.Layers()
-		if err != nil {
-			return nil, errors.Wrapf(err, "failed to get layers for previous image with repo name '%s'", imageName)
+// WithPlatform selects the image matching the given platform when the base or previous image is a manifest list.
+// The variant and osVersion are only compared when they are not empty.
+func WithPlatform(os, architecture, variant, osVersion string) ImageOption {
+	return func(r *Image) (*Image, error) {
+		r.platform = &v1.Platform{
+			OS:           os,
+			Architecture: architecture,
+			Variant:      variant,
+			OSVersion:    osVersion,
+		}
+		return r, nil
+	}
+}
+
+// WithContext sets the context used for registry requests made by the image, unless a method is given its own context.
+// Layers of the base and previous images are always read using this context.
+func WithContext(ctx context.Context) ImageOption {
+	return func(r *Image) (*Image, error) {
+		r.ctx = ctx
+		return r, nil
+	}
+}
+
+// WithSaveConcurrency sets how many repositories Save pushes to at the same time, defaults to 1.
+func WithSaveConcurrency(n int) ImageOption {
+	return func(r *Image) (*Image, error) {
+		if n < 1 {
+			return nil, fmt.Errorf("save concurrency must be at least 1, got %d", n)
+		}
+		r.saveConcurrency = n
+		return r, nil
+	}
+}
+
+// WithProgress sets a function that is called as Save uploads the layers of the image.
+// Layers that already exist in the registry or are mounted from another repository aren't reported.
+func WithProgress(fn imgutil.ProgressFunc) ImageOption {
+	return func(r *Image) (*Image, error) {
+		r.progress = fn
+		return r, nil
+	}
+}
+
+// WithTransport sets the transport used for registry requests made by the image, defaults to http.DefaultTransport.
+// WithInsecureRegistries, WithCABundle and WithClientCertificate change the TLS configuration of a copy of transport,
+// so NewImage returns an error when they are combined with a transport that isn't an *http.Transport.
+func WithTransport(transport http.RoundTripper) ImageOption {
+	return func(r *Image) (*Image, error) {
+		r.transport = transport
+		return r, nil
+	}
+}
+
+// WithRetry retries registry requests made by the image that fail transiently, according to policy. A blob upload that
+// fails is retried in a new upload session rather than by sending the failed request again.
+func WithRetry(policy RetryPolicy) ImageOption {
+	return func(r *Image) (*Image, error) {
+		if policy.MaxAttempts < 1 {
+			return nil, fmt.Errorf("retry policy must allow at least 1 attempt, got %d", policy.MaxAttempts)
 		}
+		r.retryPolicy = &policy
+		return r, nil
+	}
+}
 
-		r.prevLayers = prevLayers
+// WithInsecureRegistries allows the given registries, e.g. "my-registry.io:5000", to be reached over plain HTTP
+// or over HTTPS without verifying their certificates.
+func WithInsecureRegistries(registries ...string) ImageOption {
+	return func(r *Image) (*Image, error) {
+		if r.insecureRegistries == nil {
+			r.insecureRegistries = map[string]bool{}
+		}
+		for _, registry := range registries {
+			r.insecureRegistries[registry] = true
+		}
 		return r, nil
 	}
 }
 
-func FromBaseImage(imageName string) ImageOption {
+// WithCABundle trusts the PEM encoded certificates in bundle, in addition to the system's, when verifying registries.
+func WithCABundle(bundle []byte) ImageOption {
 	return func(r *Image) (*Image, error) {
-		var err error
+		if !x509.NewCertPool().AppendCertsFromPEM(bundle) {
+			return nil, errors.New("CA bundle contains no PEM encoded certificates")
+		}
+		r.caBundles = append(r.caBundles, bundle)
+		return r, nil
+	}
+}
 
-		r.image, err = newV1Image(r.keychain, imageName)
+// WithClientCertificate presents cert to registries that ask for a client certificate.
+func WithClientCertificate(cert tls.Certificate) ImageOption {
+	return func(r *Image) (*Image, error) {
+		r.clientCerts = append(r.clientCerts, cert)
+		return r, nil
+	}
+}
+
+// WithRegistryMirrors makes the base and previous images of the given registry, e.g. "docker.io", be read from
+// the first of mirrors that has them before falling back to the registry itself. A mirror is a registry,
+// optionally followed by a path that repositories are nested under, e.g. "mirror.example.com/docker-hub".
+func WithRegistryMirrors(registry string, mirrors ...string) ImageOption {
+	return func(r *Image) (*Image, error) {
+		reg, err := name.NewRegistry(registry, name.WeakValidation)
 		if err != nil {
-			return nil, err
+			return nil, errors.Wrapf(err, "invalid registry '%s'", registry)
+		}
+		for _, mirror := range mirrors {
+			if _, err := name.NewRepository(mirror+"/some-repo", name.WeakValidation); err != nil {
+				return nil, errors.Wrapf(err, "invalid mirror '%s'", mirror)
+			}
+		}
+		if r.mirrors == nil {
+			r.mirrors = map[string][]string{}
 		}
+		r.mirrors[reg.RegistryStr()] = append(r.mirrors[reg.RegistryStr()], mirrors...)
 		return r, nil
 	}
 }
 
 func NewImage(repoName string, keychain authn.Keychain, ops ...ImageOption) (imgutil.Image, error) {
-	image, err := emptyImage()
+	ri, err := newImage(repoName, keychain, ops)
 	if err != nil {
 		return nil, err
 	}
 
+	if ri.platform != nil {
+		ri.variant = ri.platform.Variant
+	}
+	if ri.baseImageName != "" {
+		var variant string
+		ri.V1Image, ri.baseImageSource, variant, err = ri.newV1Image(ri.baseImageName, ri.baseImageStrict)
+		if variant != "" {
+			ri.variant = variant
+		}
+	} else {
+		ri.V1Image, err = v1image.Empty(ri.platform)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if ri.BaseLayers, err = v1image.LayerCount(ri.V1Image); err != nil {
+		return nil, err
+	}
+
+	if ri.prevImageName != "" {
+		var prevImage v1.Image
+		prevImage, ri.prevImageSource, _, err = ri.newV1Image(ri.prevImageName, ri.prevImageStrict)
+		if err != nil {
+			return nil, err
+		}
+
+		ri.PrevLayers, err = prevImage.Layers()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get layers for previous image with repo name '%s'", ri.prevImageName)
+		}
+	}
+
+	return ri, nil
+}
+
+// newImage returns an image of repoName configured by ops, whose transport makes registry requests as they ask for.
+// Its base and previous images aren't read yet.
+func newImage(repoName string, keychain authn.Keychain, ops []ImageOption) (*Image, error) {
 	ri := &Image{
-		keychain: keychain,
-		repoName: repoName,
-		image:    image,
+		ctx:             context.Background(),
+		keychain:        keychain,
+		repoName:        repoName,
+		saveConcurrency: 1,
+		transport:       http.DefaultTransport,
+	}
+	ri.Describe = func() string {
+		return fmt.Sprintf("image '%s'", ri.repoName)
 	}
 
+	var err error
 	for _, op := range ops {
 		ri, err = op(ri)
 		if err != nil {
@@ -80,100 +276,221 @@ func NewImage(repoName string, keychain authn.Keychain, ops ...ImageOption) (img
 		}
 	}
 
+	if len(ri.insecureRegistries) > 0 || len(ri.caBundles) > 0 || len(ri.clientCerts) > 0 {
+		if ri.transport, err = newTLSTransport(ri.transport, ri.caBundles, ri.clientCerts, ri.insecureRegistries); err != nil {
+			return nil, err
+		}
+	}
+	if ri.retryPolicy != nil {
+		ri.transport = newRetryTransport(ri.transport, *ri.retryPolicy)
+	}
 	return ri, nil
 }
 
-func newV1Image(keychain authn.Keychain, repoName string) (v1.Image, error) {
-	ref, auth, err := referenceForRepoName(keychain, repoName)
+// BaseImageSource returns the name the base image was read from, which is a mirror's when a mirror had the image.
+// It is empty when there is no base image.
+func (i *Image) BaseImageSource() string {
+	return i.baseImageSource
+}
+
+// PreviousImageSource returns the name the previous image was read from, which is a mirror's when a mirror had the image.
+// It is empty when there is no previous image.
+func (i *Image) PreviousImageSource() string {
+	return i.prevImageSource
+}
+
+// BaseImageFound tells whether the base image was found, as opposed to the image starting from an empty image.
+func (i *Image) BaseImageFound() bool {
+	return i.baseImageSource != ""
+}
+
+// PreviousImageFound tells whether the previous image was found, so that its layers can be reused.
+func (i *Image) PreviousImageFound() bool {
+	return i.prevImageSource != ""
+}
+
+// newV1Image returns the image repoName, read from the first of its registry's mirrors that has it and otherwise
+// from repoName itself, along with the name it was read from and its variant. Unless strict, an empty image is
+// returned when repoName doesn't exist or access to it is unauthorized.
+func (i *Image) newV1Image(repoName string, strict bool) (v1.Image, string, string, error) {
+	mirrorNames, err := i.mirrorNames(repoName)
 	if err != nil {
-		return nil, err
+		return nil, "", "", err
+	}
+	for _, mirrorName := range mirrorNames {
+		// a mirror that fails or doesn't have the image is skipped
+		if image, variant, err := i.readV1Image(mirrorName); err == nil {
+			return image, mirrorName, variant, nil
+		}
 	}
 
-	image, err := remote.Image(ref, remote.WithAuth(auth), remote.WithTransport(http.DefaultTransport))
+	image, variant, err := i.readV1Image(repoName)
 	if err != nil {
-		if transportErr, ok := err.(*transport.Error); ok && len(transportErr.Errors) > 0 {
-			switch transportErr.StatusCode {
-			case http.StatusNotFound, http.StatusUnauthorized:
-				return emptyImage()
-			}
+		if !strict && isMissingImage(err) {
+			image, err := v1image.Empty(i.platform)
+			return image, "", "", err
 		}
-		return nil, fmt.Errorf("connect to repo store '%s': %s", repoName, err.Error())
+		return nil, "", "", err
 	}
-
-	return image, nil
+	return image, repoName, variant, nil
 }
 
-func emptyImage() (v1.Image, error) {
-	cfg := &v1.ConfigFile{
-		OS:           "linux",
-		Architecture: "amd64",
-		RootFS: v1.RootFS{
-			Type:    "layers",
-			DiffIDs: []v1.Hash{},
-		},
+// mirrorNames returns the names of repoName in each mirror of its registry.
+func (i *Image) mirrorNames(repoName string) ([]string, error) {
+	if len(i.mirrors) == 0 {
+		return nil, nil
 	}
-	return mutate.ConfigFile(empty.Image, cfg)
+	ref, err := name.ParseReference(repoName, name.WeakValidation)
+	if err != nil {
+		return nil, err
+	}
+
+	separator := ":"
+	if _, ok := ref.(name.Digest); ok {
+		separator = "@"
+	}
+
+	var names []string
+	for _, mirror := range i.mirrors[ref.Context().RegistryStr()] {
+		names = append(names, mirror+"/"+ref.Context().RepositoryStr()+separator+ref.Identifier())
+	}
+	return names, nil
 }
 
-func referenceForRepoName(keychain authn.Keychain, ref string) (name.Reference, authn.Authenticator, error) {
-	var auth authn.Authenticator
-	r, err := name.ParseReference(ref, name.WeakValidation)
+// readV1Image reads the image repoName from the registry. When it is a manifest list, the image matching the platform
+// of i is read, and the variant of that image is returned.
+func (i *Image) readV1Image(repoName string) (v1.Image, string, error) {
+	ref, auth, err := i.referenceForRepoName(repoName)
 	if err != nil {
-		return nil, nil, err
+		return nil, "", err
 	}
 
-	auth, err = keychain.Resolve(r.Context().Registry)
+	desc, err := remote.Get(ref, remote.WithAuth(auth), remote.WithTransport(newTransport(i.ctx, i.transport)))
 	if err != nil {
-		return nil, nil, err
+		return nil, "", registryError(err, "connect to repo store '%s'", repoName)
 	}
-	return r, auth, nil
+
+	if i.platform != nil && isIndex(desc.MediaType) {
+		return imageForPlatform(desc, repoName, *i.platform)
+	}
+
+	image, err := desc.Image()
+	if err != nil {
+		return nil, "", registryError(err, "connect to repo store '%s'", repoName)
+	}
+
+	return image, "", nil
 }
 
-func (i *Image) Label(key string) (string, error) {
-	cfg, err := i.image.ConfigFile()
-	if err != nil || cfg == nil {
-		return "", fmt.Errorf("failed to get config file for image '%s'", i.repoName)
+// isMissingImage tells whether the registry answered a read of an image with not found or unauthorized.
+func isMissingImage(err error) bool {
+	var transportErr *transport.Error
+	if !errors.As(err, &transportErr) || len(transportErr.Errors) == 0 {
+		return false
 	}
-	labels := cfg.Config.Labels
-	return labels[key], nil
+	return transportErr.StatusCode == http.StatusNotFound || transportErr.StatusCode == http.StatusUnauthorized
+}
+
+// registryError wraps an error returned by the registry, classifying it as one of the imgutil sentinel errors when
+// the registry reported the image as missing or access as unauthorized.
+func registryError(err error, format string, args ...interface{}) error {
+	var transportErr *transport.Error
+	if errors.As(err, &transportErr) {
+		switch transportErr.StatusCode {
+		case http.StatusNotFound:
+			return imgutil.Wrapf(imgutil.ErrImageNotFound, err, format, args...)
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return imgutil.Wrapf(imgutil.ErrUnauthorized, err, format, args...)
+		}
+	}
+	return errors.Wrapf(err, format, args...)
+}
+
+func isIndex(mediaType types.MediaType) bool {
+	return mediaType == types.OCIImageIndex || mediaType == types.DockerManifestList
 }
 
-func (i *Image) Env(key string) (string, error) {
-	cfg, err := i.image.ConfigFile()
-	if err != nil || cfg == nil {
-		return "", fmt.Errorf("failed to get config file for image '%s'", i.repoName)
+func imageForPlatform(desc *remote.Descriptor, repoName string, platform v1.Platform) (v1.Image, string, error) {
+	index, err := desc.ImageIndex()
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "read manifest list '%s'", repoName)
 	}
-	for _, envVar := range cfg.Config.Env {
-		parts := strings.Split(envVar, "=")
-		if parts[0] == key {
-			return parts[1], nil
+
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "read manifest list '%s'", repoName)
+	}
+
+	var available []string
+	for _, child := range manifest.Manifests {
+		if child.Platform == nil {
+			continue
 		}
+		if matchesPlatform(*child.Platform, platform) {
+			image, err := index.Image(child.Digest)
+			return image, child.Platform.Variant, err
+		}
+		available = append(available, platformString(*child.Platform))
 	}
-	return "", nil
+
+	return nil, "", imgutil.Errorf(
+		imgutil.ErrPlatformMismatch,
+		"no image in manifest list '%s' matches platform '%s', available platforms: [%s]",
+		repoName,
+		platformString(platform),
+		strings.Join(available, ", "),
+	)
 }
 
-func (i *Image) OS() (string, error) {
-	cfg, err := i.image.ConfigFile()
-	if err != nil || cfg == nil || cfg.OS == "" {
-		return "", fmt.Errorf("failed to get OS from config file for image '%s'", i.repoName)
+func matchesPlatform(given, required v1.Platform) bool {
+	if given.OS != required.OS || given.Architecture != required.Architecture {
+		return false
+	}
+	if required.Variant != "" && given.Variant != required.Variant {
+		return false
 	}
-	return cfg.OS, nil
+	if required.OSVersion != "" && given.OSVersion != required.OSVersion {
+		return false
+	}
+	return true
 }
 
-func (i *Image) OSVersion() (string, error) {
-	cfg, err := i.image.ConfigFile()
-	if err != nil || cfg == nil {
-		return "", fmt.Errorf("failed to get OSVersion from config file for image '%s'", i.repoName)
+func platformString(platform v1.Platform) string {
+	s := platform.OS + "/" + platform.Architecture
+	if platform.Variant != "" {
+		s += "/" + platform.Variant
+	}
+	if platform.OSVersion != "" {
+		s += ":" + platform.OSVersion
 	}
-	return cfg.OSVersion, nil
+	return s
 }
 
-func (i *Image) Architecture() (string, error) {
-	cfg, err := i.image.ConfigFile()
-	if err != nil || cfg == nil || cfg.Architecture == "" {
-		return "", fmt.Errorf("failed to get Architecture from config file for image '%s'", i.repoName)
+// referenceForRepoName parses repoName, allowing plain HTTP for insecure registries.
+func (i *Image) referenceForRepoName(repoName string) (name.Reference, authn.Authenticator, error) {
+	ref, auth, err := referenceForRepoName(i.keychain, repoName)
+	if err != nil || !i.insecureRegistries[ref.Context().RegistryStr()] {
+		return ref, auth, err
 	}
-	return cfg.Architecture, nil
+	ref, err = name.ParseReference(repoName, name.WeakValidation, name.Insecure)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ref, auth, nil
+}
+
+func referenceForRepoName(keychain authn.Keychain, ref string) (name.Reference, authn.Authenticator, error) {
+	var auth authn.Authenticator
+	r, err := name.ParseReference(ref, name.WeakValidation)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	auth, err = keychain.Resolve(r.Context().Registry)
+	if err != nil {
+		return nil, nil, err
+	}
+	return r, auth, nil
 }
 
 func (i *Image) Rename(name string) {
@@ -185,249 +502,338 @@ func (i *Image) Name() string {
 }
 
 func (i *Image) Found() bool {
-	ref, auth, err := referenceForRepoName(i.keychain, i.repoName)
-	if err != nil {
-		return false
+	found, err := i.Exists()
+	return err == nil && found
+}
+
+// Exists tells whether the image exists in the repository by `Name()`, using a HEAD request for its manifest. Unlike
+// Found, it returns an error when the registry can't be reached or refuses access.
+func (i *Image) Exists() (bool, error) {
+	_, err := i.headManifest()
+	if errors.Is(err, imgutil.ErrImageNotFound) {
+		return false, nil
 	}
-	_, err = remote.Image(ref, remote.WithAuth(auth), remote.WithTransport(http.DefaultTransport))
-	return err == nil
+	return err == nil, err
 }
 
-func (i *Image) Identifier() (imgutil.Identifier, error) {
-	ref, err := name.ParseReference(i.repoName, name.WeakValidation)
+// RemoteDigest returns the digest of the manifest `Name()` refers to in the registry, without downloading the
+// manifest when the registry includes the digest in its response to a HEAD request. It doesn't reflect changes made to
+// the image that haven't been saved.
+func (i *Image) RemoteDigest() (v1.Hash, error) {
+	digest, err := i.headManifest()
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse reference for image '%s': %s", i.repoName, err)
+		return v1.Hash{}, err
+	}
+	if digest != "" {
+		return v1.NewHash(digest)
 	}
 
-	hash, err := i.image.Digest()
+	ref, auth, err := i.referenceForRepoName(i.repoName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get digest for image '%s': %s", i.repoName, err)
+		return v1.Hash{}, err
 	}
-
-	digestRef, err := name.NewDigest(fmt.Sprintf("%s@%s", ref.Context().Name(), hash.String()), name.WeakValidation)
+	desc, err := remote.Get(ref, remote.WithAuth(auth), remote.WithTransport(newTransport(i.ctx, i.transport)))
 	if err != nil {
-		return nil, errors.Wrap(err, "creating digest reference")
+		return v1.Hash{}, registryError(err, "get digest for image '%s'", i.repoName)
 	}
+	return desc.Digest, nil
+}
 
-	return DigestIdentifier{
-		Digest: digestRef,
-	}, nil
+var acceptedManifestTypes = []string{
+	string(types.OCIManifestSchema1),
+	string(types.DockerManifestSchema2),
+	string(types.OCIImageIndex),
+	string(types.DockerManifestList),
 }
 
-func (i *Image) CreatedAt() (time.Time, error) {
-	configFile, err := i.image.ConfigFile()
+// headManifest makes a HEAD request for the manifest of `Name()`, returning the digest the registry reported for it,
+// if any.
+func (i *Image) headManifest() (string, error) {
+	ref, auth, err := i.referenceForRepoName(i.repoName)
 	if err != nil {
-		return time.Time{}, fmt.Errorf("failed to get createdAt time for image '%s': %s", i.repoName, err)
+		return "", err
 	}
-	return configFile.Created.UTC(), nil
-}
 
-func (i *Image) Rebase(baseTopLayer string, newBase imgutil.Image) error {
-	newBaseRemote, ok := newBase.(*Image)
-	if !ok {
-		return errors.New("expected new base to be a remote image")
+	rt, err := transport.New(ref.Context().Registry, auth, newTransport(i.ctx, i.transport), []string{ref.Scope(transport.PullScope)})
+	if err != nil {
+		return "", registryError(err, "connect to repo store '%s'", i.repoName)
 	}
 
-	newImage, err := mutate.Rebase(i.image, &subImage{img: i.image, topDiffID: baseTopLayer}, newBaseRemote.image)
+	u := url.URL{
+		Scheme: ref.Context().Registry.Scheme(),
+		Host:   ref.Context().RegistryStr(),
+		Path:   fmt.Sprintf("/v2/%s/manifests/%s", ref.Context().RepositoryStr(), ref.Identifier()),
+	}
+	req, err := http.NewRequest(http.MethodHead, u.String(), nil)
 	if err != nil {
-		return errors.Wrap(err, "rebase")
+		return "", err
 	}
-	i.image = newImage
-	return nil
-}
+	req.Header.Set("Accept", strings.Join(acceptedManifestTypes, ","))
 
-func (i *Image) SetLabel(key, val string) error {
-	configFile, err := i.image.ConfigFile()
+	resp, err := (&http.Client{Transport: rt}).Do(req.WithContext(i.ctx))
 	if err != nil {
-		return err
+		return "", errors.Wrapf(err, "check image '%s'", i.repoName)
 	}
-	config := *configFile.Config.DeepCopy()
-	if config.Labels == nil {
-		config.Labels = map[string]string{}
+	defer resp.Body.Close()
+
+	if err := transport.CheckError(resp, http.StatusOK); err != nil {
+		return "", registryError(err, "check image '%s'", i.repoName)
 	}
-	config.Labels[key] = val
-	i.image, err = mutate.Config(i.image, config)
-	return err
+	return resp.Header.Get("Docker-Content-Digest"), nil
 }
 
-func (i *Image) SetEnv(key, val string) error {
-	configFile, err := i.image.ConfigFile()
+func (i *Image) Identifier() (imgutil.Identifier, error) {
+	ref, err := name.ParseReference(i.repoName, name.WeakValidation)
 	if err != nil {
-		return err
-	}
-	config := *configFile.Config.DeepCopy()
-	for idx, e := range config.Env {
-		parts := strings.Split(e, "=")
-		if parts[0] == key {
-			config.Env[idx] = fmt.Sprintf("%s=%s", key, val)
-			i.image, err = mutate.Config(i.image, config)
-			if err != nil {
-				return err
-			}
-			return nil
-		}
+		return nil, fmt.Errorf("failed to parse reference for image '%s': %s", i.repoName, err)
 	}
-	config.Env = append(config.Env, fmt.Sprintf("%s=%s", key, val))
-	i.image, err = mutate.Config(i.image, config)
-	return err
-}
 
-func (i *Image) SetWorkingDir(dir string) error {
-	configFile, err := i.image.ConfigFile()
+	hash, err := i.V1Image.Digest()
 	if err != nil {
-		return err
+		return nil, registryError(err, "failed to get digest for image '%s'", i.repoName)
 	}
-	config := *configFile.Config.DeepCopy()
-	config.WorkingDir = dir
-	i.image, err = mutate.Config(i.image, config)
-	return err
-}
 
-func (i *Image) SetEntrypoint(ep ...string) error {
-	configFile, err := i.image.ConfigFile()
+	digestRef, err := name.NewDigest(fmt.Sprintf("%s@%s", ref.Context().Name(), hash.String()), name.WeakValidation)
 	if err != nil {
-		return err
+		return nil, errors.Wrap(err, "creating digest reference")
 	}
-	config := *configFile.Config.DeepCopy()
-	config.Entrypoint = ep
-	i.image, err = mutate.Config(i.image, config)
-	return err
+
+	return DigestIdentifier{
+		Digest: digestRef,
+	}, nil
 }
 
-func (i *Image) SetCmd(cmd ...string) error {
-	configFile, err := i.image.ConfigFile()
+func (i *Image) CreatedAt() (time.Time, error) {
+	configFile, err := i.V1Image.ConfigFile()
 	if err != nil {
-		return err
+		return time.Time{}, registryError(err, "failed to get createdAt time for image '%s'", i.repoName)
 	}
-	config := *configFile.Config.DeepCopy()
-	config.Cmd = cmd
-	i.image, err = mutate.Config(i.image, config)
-	return err
+	return configFile.Created.UTC(), nil
 }
 
-func (i *Image) TopLayer() (string, error) {
-	all, err := i.image.Layers()
-	if err != nil {
-		return "", err
+func (i *Image) Rebase(baseTopLayer string, newBase imgutil.Image, ops ...imgutil.RebaseOption) error {
+	return i.RebaseContext(i.ctx, baseTopLayer, newBase, ops...)
+}
+
+func (i *Image) RebaseContext(ctx context.Context, baseTopLayer string, newBase imgutil.Image, ops ...imgutil.RebaseOption) error {
+	return i.RebaseOnto(ctx, i, baseTopLayer, newBase, ops...)
+}
+
+func (i *Image) Save(additionalNames ...string) error {
+	return i.SaveContext(i.ctx, additionalNames...)
+}
+
+func (i *Image) SaveContext(ctx context.Context, additionalNames ...string) error {
+	allNames := append([]string{i.repoName}, additionalNames...)
+
+	if err := i.Normalize(); err != nil {
+		return err
 	}
-	if len(all) == 0 {
-		return "", fmt.Errorf("image %s has no layers", i.Name())
+
+	var diagnostics []imgutil.SaveDiagnostic
+	for idx, err := range i.saveAll(ctx, allNames) {
+		if err != nil {
+			diagnostics = append(diagnostics, imgutil.SaveDiagnostic{ImageName: allNames[idx], Cause: err})
+		}
 	}
-	topLayer := all[len(all)-1]
-	hex, err := topLayer.DiffID()
-	if err != nil {
-		return "", err
+	if len(diagnostics) > 0 {
+		return imgutil.SaveError{Errors: diagnostics}
 	}
-	return hex.String(), nil
+
+	return nil
 }
 
-func (i *Image) GetLayer(sha string) (io.ReadCloser, error) {
-	layers, err := i.image.Layers()
-	if err != nil {
-		return nil, err
-	}
+// saveAll saves the image to every name and returns the errors in the order of names.
+// Blobs are pushed once per repository, along with the first name in it, after which only the manifest is written
+// for the other names in the repository. Up to saveConcurrency repositories are pushed to at the same time.
+func (i *Image) saveAll(ctx context.Context, names []string) []error {
+	errs := make([]error, len(names))
 
-	layer, err := findLayerWithSha(layers, sha)
-	if err != nil {
-		return nil, err
+	var repos []string
+	namesByRepo := map[string][]int{}
+	for idx, n := range names {
+		ref, err := name.ParseReference(n, name.WeakValidation)
+		if err != nil {
+			errs[idx] = err
+			continue
+		}
+		repo := ref.Context().String()
+		if _, ok := namesByRepo[repo]; !ok {
+			repos = append(repos, repo)
+		}
+		namesByRepo[repo] = append(namesByRepo[repo], idx)
+	}
+
+	sem := make(chan struct{}, i.saveConcurrency)
+	var wg sync.WaitGroup
+	for _, repo := range repos {
+		idxs := namesByRepo[repo]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			first := idxs[0]
+			errs[first] = i.doSave(ctx, names[first])
+			for _, idx := range idxs[1:] {
+				if errs[first] != nil {
+					// the blobs may be missing from the repository, so the name gets a full push of its own
+					errs[idx] = i.doSave(ctx, names[idx])
+					continue
+				}
+				errs[idx] = i.doTag(ctx, names[idx])
+			}
+		}()
 	}
+	wg.Wait()
 
-	return layer.Uncompressed()
+	return errs
 }
 
-func (i *Image) AddLayer(path string) error {
-	layer, err := tarball.LayerFromFile(path)
+// doTag writes the manifest of the image to imageName, whose repository must already have the image's blobs.
+func (i *Image) doTag(ctx context.Context, imageName string) error {
+	ref, auth, err := i.referenceForRepoName(imageName)
 	if err != nil {
 		return err
 	}
-	i.image, err = mutate.AppendLayers(i.image, layer)
-	if err != nil {
-		return errors.Wrap(err, "add layer")
+	tag, ok := ref.(name.Tag)
+	if !ok {
+		return i.doSave(ctx, imageName)
 	}
-	return nil
+	return remote.Tag(tag, i.V1Image, remote.WithAuth(auth), remote.WithTransport(newTransport(ctx, i.transport)))
 }
 
-func (i *Image) AddLayerWithDiffID(path, diffID string) error {
-	// this is equivalent to AddLayer in the remote case
-	// it exists to provide optimize performance for local images
-	return i.AddLayer(path)
-}
-
-func (i *Image) ReuseLayer(sha string) error {
-	layer, err := findLayerWithSha(i.prevLayers, sha)
+func (i *Image) doSave(ctx context.Context, imageName string) error {
+	ref, auth, err := i.referenceForRepoName(imageName)
 	if err != nil {
 		return err
 	}
-	i.image, err = mutate.AppendLayers(i.image, layer)
+	writeImage := func(image v1.Image) func(http.RoundTripper) error {
+		return func(rt http.RoundTripper) error {
+			return remote.Write(ref, i.withProgress(image), remote.WithAuth(auth), remote.WithTransport(rt))
+		}
+	}
+
+	// layers read from another repository on the same registry, i.e. those of the base and previous images,
+	// are mounted rather than uploaded. When the registry refuses a mount the layers are uploaded instead.
+	wt, err := i.write(ctx, writeImage(i.V1Image))
+	if err != nil && isMountRefused(err) && wt.refusedMount() && hasMountableLayers(i.V1Image, ref) {
+		_, err = i.write(ctx, writeImage(&unmountableImage{Image: i.V1Image}))
+	}
 	return err
 }
 
-func findLayerWithSha(layers []v1.Layer, diffID string) (v1.Layer, error) {
-	for _, layer := range layers {
-		dID, err := layer.DiffID()
-		if err != nil {
-			return nil, errors.Wrap(err, "get diff ID for previous image layer")
+// write calls write with the transport to write to the registry with, and returns that transport for the last call.
+// When a blob upload fails transiently write is called again, as the retry policy allows, which uploads the blobs the
+// registry doesn't have yet in new upload sessions.
+func (i *Image) write(ctx context.Context, write func(rt http.RoundTripper) error) (*writeTransport, error) {
+	for attempt := 1; ; attempt++ {
+		wt := &writeTransport{inner: newTransport(ctx, i.transport)}
+		err := write(wt)
+		if err == nil || i.retryPolicy == nil || attempt >= i.retryPolicy.MaxAttempts || !wt.failedUpload() {
+			return wt, err
 		}
-		if diffID == dID.String() {
-			return layer, nil
+		if err := sleep(ctx, i.retryPolicy.backoff(attempt, 0)); err != nil {
+			return wt, err
 		}
 	}
-	return nil, fmt.Errorf(`previous image did not have layer with diff id '%s'`, diffID)
 }
 
-func (i *Image) Save(additionalNames ...string) error {
-	var err error
+func (i *Image) withProgress(image v1.Image) v1.Image {
+	if i.progress == nil {
+		return image
+	}
+	return &progressImage{Image: image, fn: i.progress}
+}
 
-	allNames := append([]string{i.repoName}, additionalNames...)
+// progressImage reports the progress of reading the compressed contents of its layers.
+type progressImage struct {
+	v1.Image
+	fn imgutil.ProgressFunc
+}
 
-	i.image, err = mutate.CreatedAt(i.image, v1.Time{Time: imgutil.NormalizedDateTime})
+func (pi *progressImage) Layers() ([]v1.Layer, error) {
+	layers, err := pi.Image.Layers()
 	if err != nil {
-		return errors.Wrap(err, "set creation time")
+		return nil, err
 	}
+	for idx, layer := range layers {
+		if ml, ok := layer.(*remote.MountableLayer); ok {
+			layers[idx] = &remote.MountableLayer{Layer: &progressLayer{Layer: ml.Layer, fn: pi.fn}, Reference: ml.Reference}
+			continue
+		}
+		layers[idx] = &progressLayer{Layer: layer, fn: pi.fn}
+	}
+	return layers, nil
+}
 
-	cfg, err := i.image.ConfigFile()
+type progressLayer struct {
+	v1.Layer
+	fn imgutil.ProgressFunc
+}
+
+func (pl *progressLayer) Compressed() (io.ReadCloser, error) {
+	digest, err := pl.Layer.Digest()
 	if err != nil {
-		return errors.Wrap(err, "get image config")
+		return nil, err
 	}
-	cfg = cfg.DeepCopy()
-
-	layers, err := i.image.Layers()
+	size, err := pl.Layer.Size()
 	if err != nil {
-		return errors.Wrap(err, "get image layers")
+		size = -1
 	}
-	cfg.History = make([]v1.History, len(layers))
-	for i := range cfg.History {
-		cfg.History[i] = v1.History{
-			Created: v1.Time{Time: imgutil.NormalizedDateTime},
-		}
+	rc, err := pl.Layer.Compressed()
+	if err != nil {
+		return nil, err
 	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{imgutil.NewProgressReader(rc, digest.String(), size, pl.fn), rc}, nil
+}
 
-	cfg.DockerVersion = ""
-	cfg.Container = ""
-	i.image, err = mutate.ConfigFile(i.image, cfg)
-	if err != nil {
-		return errors.Wrap(err, "zeroing history")
+func isMountRefused(err error) bool {
+	var transportErr *transport.Error
+	return errors.As(err, &transportErr) && isRefusal(transportErr.StatusCode)
+}
+
+func isRefusal(statusCode int) bool {
+	switch statusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		return true
 	}
+	return false
+}
 
-	var diagnostics []imgutil.SaveDiagnostic
-	for _, n := range allNames {
-		if err := i.doSave(n); err != nil {
-			diagnostics = append(diagnostics, imgutil.SaveDiagnostic{ImageName: n, Cause: err})
-		}
+func hasMountableLayers(image v1.Image, ref name.Reference) bool {
+	layers, err := image.Layers()
+	if err != nil {
+		return false
 	}
-	if len(diagnostics) > 0 {
-		return imgutil.SaveError{Errors: diagnostics}
+	for _, layer := range layers {
+		if ml, ok := layer.(*remote.MountableLayer); ok && ml.Reference.Context().String() != ref.Context().String() {
+			return true
+		}
 	}
+	return false
+}
 
-	return nil
+// unmountableImage hides where the layers of an image were read from, so that they are uploaded rather than mounted.
+type unmountableImage struct {
+	v1.Image
 }
 
-func (i *Image) doSave(imageName string) error {
-	ref, auth, err := referenceForRepoName(i.keychain, imageName)
+func (ui *unmountableImage) Layers() ([]v1.Layer, error) {
+	layers, err := ui.Image.Layers()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	for idx, layer := range layers {
+		if ml, ok := layer.(*remote.MountableLayer); ok {
+			layers[idx] = ml.Layer
+		}
 	}
-	return remote.Write(ref, i.image, remote.WithAuth(auth))
+	return layers, nil
 }
 
 func (i *Image) Delete() error {
@@ -435,42 +841,12 @@ func (i *Image) Delete() error {
 	if err != nil {
 		return err
 	}
-	ref, auth, err := referenceForRepoName(i.keychain, id.String())
+	ref, auth, err := i.referenceForRepoName(id.String())
 	if err != nil {
 		return err
 	}
-	return remote.Delete(ref, remote.WithAuth(auth))
-}
-
-type subImage struct {
-	img       v1.Image
-	topDiffID string
-}
-
-func (si *subImage) Layers() ([]v1.Layer, error) {
-	all, err := si.img.Layers()
-	if err != nil {
-		return nil, err
+	if err := remote.Delete(ref, remote.WithAuth(auth), remote.WithTransport(newTransport(i.ctx, i.transport))); err != nil {
+		return registryError(err, "delete image '%s'", i.repoName)
 	}
-	for i, l := range all {
-		d, err := l.DiffID()
-		if err != nil {
-			return nil, err
-		}
-		if d.String() == si.topDiffID {
-			return all[0 : i+1], nil
-		}
-	}
-	return nil, errors.New("could not find base layer in image")
-}
-func (si *subImage) BlobSet() (map[v1.Hash]struct{}, error)  { panic("Not Implemented") }
-func (si *subImage) MediaType() (types.MediaType, error)     { panic("Not Implemented") }
-func (si *subImage) ConfigName() (v1.Hash, error)            { panic("Not Implemented") }
-func (si *subImage) ConfigFile() (*v1.ConfigFile, error)     { panic("Not Implemented") }
-func (si *subImage) RawConfigFile() ([]byte, error)          { panic("Not Implemented") }
-func (si *subImage) Digest() (v1.Hash, error)                { panic("Not Implemented") }
-func (si *subImage) Manifest() (*v1.Manifest, error)         { panic("Not Implemented") }
-func (si *subImage) RawManifest() ([]byte, error)            { panic("Not Implemented") }
-func (si *subImage) LayerByDigest(v1.Hash) (v1.Layer, error) { panic("Not Implemented") }
-func (si *subImage) LayerByDiffID(v1.Hash) (v1.Layer, error) { panic("Not Implemented") }
-func (si *subImage) Size() (int64, error)                    { panic("Not Implemented") }
+	return nil
+}
@@ -1,37 +1,79 @@
 package remote_test
 
 import (
+	"compress/gzip"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"math/rand"
+	"net/http"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	ggcrremote "github.com/google/go-containerregistry/pkg/v1/remote"
+	ggcrtarball "github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/sclevine/spec"
 	"github.com/sclevine/spec/report"
 
 	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/imgutiltest"
+	"github.com/buildpacks/imgutil/layout"
 	"github.com/buildpacks/imgutil/remote"
 	h "github.com/buildpacks/imgutil/testhelpers"
 )
 
-var registryPort string
+var registryHost string
 
 func newTestImageName() string {
-	return "localhost:" + registryPort + "/pack-image-test-" + h.RandString(10)
+	return registryHost + "/pack-image-test-" + h.RandString(10)
 }
 
 func TestRemote(t *testing.T) {
 	rand.Seed(time.Now().UTC().UnixNano())
 
-	dockerRegistry := h.NewDockerRegistry()
-	dockerRegistry.Start(t)
-	defer dockerRegistry.Stop(t)
+	registry := h.NewTestRegistry()
+	registry.Start(t)
+	defer registry.Stop(t)
 
-	registryPort = dockerRegistry.Port
+	registryHost = registry.Host()
 
 	spec.Run(t, "Image", testImage, spec.Sequential(), spec.Report(report.Terminal{}))
+	spec.Run(t, "ImageIndex", testImageIndex, spec.Sequential(), spec.Report(report.Terminal{}))
+	imgutiltest.Run(t, conformanceFactory())
+}
+
+func conformanceFactory() imgutiltest.Factory {
+	return imgutiltest.Factory{
+		NewName: func(t *testing.T) string {
+			return newTestImageName()
+		},
+		NewImage: func(t *testing.T, name, baseName, prevName string) imgutil.Image {
+			var ops []remote.ImageOption
+			if baseName != "" {
+				ops = append(ops, remote.FromBaseImage(baseName))
+			}
+			if prevName != "" {
+				ops = append(ops, remote.WithPreviousImage(prevName))
+			}
+			img, err := remote.NewImage(name, authn.DefaultKeychain, ops...)
+			h.AssertNil(t, err)
+			return img
+		},
+	}
 }
 
 func testImage(t *testing.T, when spec.G, it spec.S) {
@@ -167,6 +209,125 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 
 					h.AssertNil(t, err)
 				})
+
+				when("#WithPlatform", func() {
+					it("uses the platform for the empty image", func() {
+						img, err := remote.NewImage(
+							repoName,
+							authn.DefaultKeychain,
+							remote.FromBaseImage(newTestImageName()),
+							remote.WithPlatform("windows", "arm64", "", "10.0.17763.1040"),
+						)
+						h.AssertNil(t, err)
+
+						os, err := img.OS()
+						h.AssertNil(t, err)
+						h.AssertEq(t, os, "windows")
+
+						osVersion, err := img.OSVersion()
+						h.AssertNil(t, err)
+						h.AssertEq(t, osVersion, "10.0.17763.1040")
+
+						arch, err := img.Architecture()
+						h.AssertNil(t, err)
+						h.AssertEq(t, arch, "arm64")
+					})
+				})
+			})
+
+			when("base image is a manifest list", func() {
+				var manifestListName string
+
+				it.Before(func() {
+					manifestListName = newTestImageName()
+					pushManifestList(t, manifestListName,
+						v1.Platform{OS: "linux", Architecture: "amd64"},
+						v1.Platform{OS: "linux", Architecture: "arm", Variant: "v6"},
+						v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"},
+						v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"},
+					)
+				})
+
+				when("#WithPlatform", func() {
+					it("selects the image matching the platform", func() {
+						img, err := remote.NewImage(
+							repoName,
+							authn.DefaultKeychain,
+							remote.FromBaseImage(manifestListName),
+							remote.WithPlatform("linux", "arm64", "", ""),
+						)
+						h.AssertNil(t, err)
+
+						arch, err := img.Architecture()
+						h.AssertNil(t, err)
+						h.AssertEq(t, arch, "arm64")
+					})
+
+					it("selects the image matching the variant", func() {
+						img, err := remote.NewImage(
+							repoName,
+							authn.DefaultKeychain,
+							remote.FromBaseImage(manifestListName),
+							remote.WithPlatform("linux", "arm", "v7", ""),
+						)
+						h.AssertNil(t, err)
+
+						label, err := img.Label("platform")
+						h.AssertNil(t, err)
+						h.AssertEq(t, label, "linux/arm/v7")
+					})
+
+					it("applies to the previous image", func() {
+						img, err := remote.NewImage(
+							repoName,
+							authn.DefaultKeychain,
+							remote.WithPreviousImage(manifestListName),
+							remote.WithPlatform("linux", "arm", "v6", ""),
+						)
+						h.AssertNil(t, err)
+
+						prevImg, err := remote.NewImage(
+							repoName,
+							authn.DefaultKeychain,
+							remote.FromBaseImage(manifestListName),
+							remote.WithPlatform("linux", "arm", "v6", ""),
+						)
+						h.AssertNil(t, err)
+
+						topLayer, err := prevImg.TopLayer()
+						h.AssertNil(t, err)
+						h.AssertNil(t, img.ReuseLayer(topLayer))
+					})
+
+					it("returns an error when no image matches", func() {
+						_, err := remote.NewImage(
+							repoName,
+							authn.DefaultKeychain,
+							remote.FromBaseImage(manifestListName),
+							remote.WithPlatform("windows", "amd64", "", ""),
+						)
+						h.AssertError(t, err, fmt.Sprintf(
+							"no image in manifest list '%s' matches platform 'windows/amd64', available platforms: [linux/amd64, linux/arm/v6, linux/arm/v7, linux/arm64/v8]",
+							manifestListName,
+						))
+						h.AssertEq(t, errors.Is(err, imgutil.ErrPlatformMismatch), true)
+					})
+				})
+			})
+		})
+
+		when("#WithContext", func() {
+			it("uses the context for registry requests", func() {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+
+				_, err := remote.NewImage(
+					repoName,
+					authn.DefaultKeychain,
+					remote.WithContext(ctx),
+					remote.FromBaseImage(newTestImageName()),
+				)
+				h.AssertError(t, err, "context canceled")
 			})
 		})
 
@@ -185,86 +346,67 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 		})
 	})
 
-	when("#Label", func() {
+	when("config getters", func() {
 		when("image exists", func() {
+			var baseImageName = newTestImageName()
+
 			it.Before(func() {
-				baseImage, err := remote.NewImage(repoName, authn.DefaultKeychain)
+				baseImage, err := remote.NewImage(baseImageName, authn.DefaultKeychain)
 				h.AssertNil(t, err)
-
-				h.AssertNil(t, baseImage.SetLabel("mykey", "myvalue"))
-				h.AssertNil(t, baseImage.SetLabel("other", "data"))
+				h.AssertNil(t, baseImage.SetLabel("some.label", "some.value"))
+				h.AssertNil(t, baseImage.SetEnv("MY_VAR", "my=val"))
+				h.AssertNil(t, baseImage.SetEntrypoint("some", "entrypoint"))
+				h.AssertNil(t, baseImage.SetCmd("some", "cmd"))
+				h.AssertNil(t, baseImage.SetWorkingDir("/some/work/dir"))
 				h.AssertNil(t, baseImage.Save())
 			})
 
-			it("returns the label value", func() {
-				img, err := remote.NewImage(repoName, authn.DefaultKeychain, remote.FromBaseImage(repoName))
+			it("returns the config values", func() {
+				img, err := remote.NewImage(repoName, authn.DefaultKeychain, remote.FromBaseImage(baseImageName))
 				h.AssertNil(t, err)
 
-				label, err := img.Label("mykey")
+				labels, err := img.Labels()
 				h.AssertNil(t, err)
-				h.AssertEq(t, label, "myvalue")
-			})
+				h.AssertEq(t, labels, map[string]string{"some.label": "some.value"})
 
-			it("returns an empty string for a missing label", func() {
-				img, err := remote.NewImage(repoName, authn.DefaultKeychain, remote.FromBaseImage(repoName))
+				envVars, err := img.EnvVars()
 				h.AssertNil(t, err)
+				h.AssertEq(t, envVars["MY_VAR"], "my=val")
 
-				label, err := img.Label("missing-label")
+				entrypoint, err := img.Entrypoint()
 				h.AssertNil(t, err)
-				h.AssertEq(t, label, "")
-			})
-		})
+				h.AssertEq(t, entrypoint, []string{"some", "entrypoint"})
 
-		when("image is empty", func() {
-			it("returns an empty label", func() {
-				img, err := remote.NewImage(repoName, authn.DefaultKeychain)
+				cmd, err := img.Cmd()
 				h.AssertNil(t, err)
+				h.AssertEq(t, cmd, []string{"some", "cmd"})
 
-				label, err := img.Label("some-label")
+				workingDir, err := img.WorkingDir()
 				h.AssertNil(t, err)
-				h.AssertEq(t, label, "")
+				h.AssertEq(t, workingDir, "/some/work/dir")
 			})
 		})
-	})
-
-	when("#Env", func() {
-		when("image exists", func() {
-			var baseImageName = newTestImageName()
-
-			it.Before(func() {
-				baseImage, err := remote.NewImage(baseImageName, authn.DefaultKeychain)
-				h.AssertNil(t, err)
-				h.AssertNil(t, baseImage.SetEnv("MY_VAR", "my_val"))
-				h.AssertNil(t, baseImage.Save())
-			})
-
-			it("returns the label value", func() {
-				img, err := remote.NewImage(repoName, authn.DefaultKeychain, remote.FromBaseImage(baseImageName))
-				h.AssertNil(t, err)
 
-				val, err := img.Env("MY_VAR")
+		when("image is empty", func() {
+			it("returns zero values", func() {
+				img, err := remote.NewImage(repoName, authn.DefaultKeychain)
 				h.AssertNil(t, err)
-				h.AssertEq(t, val, "my_val")
-			})
 
-			it("returns an empty string for a missing label", func() {
-				img, err := remote.NewImage(repoName, authn.DefaultKeychain, remote.FromBaseImage(baseImageName))
+				user, err := img.User()
 				h.AssertNil(t, err)
+				h.AssertEq(t, user, "")
 
-				val, err := img.Env("MISSING_VAR")
+				ports, err := img.ExposedPorts()
 				h.AssertNil(t, err)
-				h.AssertEq(t, val, "")
-			})
-		})
+				h.AssertEq(t, len(ports), 0)
 
-		when("image is empty", func() {
-			it("returns an empty string", func() {
-				img, err := remote.NewImage(repoName, authn.DefaultKeychain)
+				volumes, err := img.Volumes()
 				h.AssertNil(t, err)
+				h.AssertEq(t, len(volumes), 0)
 
-				val, err := img.Env("SOME_VAR")
+				stopSignal, err := img.StopSignal()
 				h.AssertNil(t, err)
-				h.AssertEq(t, val, "")
+				h.AssertEq(t, stopSignal, "")
 			})
 		})
 	})
@@ -350,56 +492,6 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 		})
 	})
 
-	when("#SetLabel", func() {
-		when("image exists", func() {
-			it("sets label on img object", func() {
-				img, err := remote.NewImage(repoName, authn.DefaultKeychain)
-				h.AssertNil(t, err)
-
-				h.AssertNil(t, img.SetLabel("mykey", "new-val"))
-				label, err := img.Label("mykey")
-				h.AssertNil(t, err)
-				h.AssertEq(t, label, "new-val")
-			})
-
-			it("saves label", func() {
-				img, err := remote.NewImage(repoName, authn.DefaultKeychain)
-				h.AssertNil(t, err)
-
-				h.AssertNil(t, img.SetLabel("mykey", "new-val"))
-
-				h.AssertNil(t, img.Save())
-
-				testImg, err := remote.NewImage(
-					"test",
-					authn.DefaultKeychain,
-					remote.FromBaseImage(repoName),
-				)
-				h.AssertNil(t, err)
-
-				remoteLabel, err := testImg.Label("mykey")
-				h.AssertNil(t, err)
-
-				h.AssertEq(t, remoteLabel, "new-val")
-			})
-		})
-	})
-
-	when("#SetEnv", func() {
-		it("sets the environment", func() {
-			img, err := remote.NewImage(repoName, authn.DefaultKeychain)
-			h.AssertNil(t, err)
-
-			err = img.SetEnv("ENV_KEY", "ENV_VAL")
-			h.AssertNil(t, err)
-
-			h.AssertNil(t, img.Save())
-
-			configFile := h.FetchManifestImageConfigFile(t, repoName)
-			h.AssertContains(t, configFile.Config.Env, "ENV_KEY=ENV_VAL")
-		})
-	})
-
 	when("#SetWorkingDir", func() {
 		it("sets the environment", func() {
 			img, err := remote.NewImage(repoName, authn.DefaultKeychain)
@@ -445,142 +537,83 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 		})
 	})
 
-	when("#Rebase", func() {
-		when("image exists", func() {
-			var oldBase, newBase, oldTopLayerDiffID string
-			var oldBaseLayers, newBaseLayers, repoTopLayers []string
-			it.Before(func() {
-				// new base
-				newBase = "localhost:" + registryPort + "/pack-newbase-test-" + h.RandString(10)
-				newBaseLayer1Path, err := h.CreateSingleFileLayerTar("/new-base.txt", "new-base", "linux")
-				h.AssertNil(t, err)
-				defer os.Remove(newBaseLayer1Path)
-
-				newBaseLayer2Path, err := h.CreateSingleFileLayerTar("/otherfile.txt", "text-new-base", "linux")
-				h.AssertNil(t, err)
-				defer os.Remove(newBaseLayer2Path)
-
-				newBaseImage, err := remote.NewImage(newBase, authn.DefaultKeychain)
-				h.AssertNil(t, err)
-
-				err = newBaseImage.AddLayer(newBaseLayer1Path)
-				h.AssertNil(t, err)
-
-				err = newBaseImage.AddLayer(newBaseLayer2Path)
-				h.AssertNil(t, err)
-
-				h.AssertNil(t, newBaseImage.Save())
-
-				newBaseLayers = h.FetchManifestLayers(t, newBase)
-
-				// old base image
-				oldBase = "localhost:" + registryPort + "/pack-oldbase-test-" + h.RandString(10)
-				oldBaseLayer1Path, err := h.CreateSingleFileLayerTar("/old-base.txt", "old-base", "linux")
-				h.AssertNil(t, err)
-				defer os.Remove(oldBaseLayer1Path)
-
-				oldBaseLayer2Path, err := h.CreateSingleFileLayerTar("/otherfile.txt", "text-old-base", "linux")
-				h.AssertNil(t, err)
-				defer os.Remove(oldBaseLayer2Path)
-
-				oldBaseImage, err := remote.NewImage(oldBase, authn.DefaultKeychain)
-				h.AssertNil(t, err)
-
-				err = oldBaseImage.AddLayer(oldBaseLayer1Path)
-				h.AssertNil(t, err)
-
-				err = oldBaseImage.AddLayer(oldBaseLayer2Path)
-				h.AssertNil(t, err)
-
-				oldTopLayerDiffID = h.FileDiffID(t, oldBaseLayer2Path)
-
-				h.AssertNil(t, oldBaseImage.Save())
-
-				oldBaseLayers = h.FetchManifestLayers(t, oldBase)
-
-				// original image
-				origLayer1Path, err := h.CreateSingleFileLayerTar("/bmyimage.txt", "text-from-image-1", "linux")
-				h.AssertNil(t, err)
-				defer os.Remove(origLayer1Path)
-
-				origLayer2Path, err := h.CreateSingleFileLayerTar("/myimage2.txt", "text-from-image-2", "linux")
-				h.AssertNil(t, err)
-				defer os.Remove(origLayer2Path)
-
-				origImage, err := remote.NewImage(repoName, authn.DefaultKeychain, remote.FromBaseImage(oldBase))
-				h.AssertNil(t, err)
-
-				err = origImage.AddLayer(origLayer1Path)
-				h.AssertNil(t, err)
-
-				err = origImage.AddLayer(origLayer2Path)
-				h.AssertNil(t, err)
-
-				h.AssertNil(t, origImage.Save())
-
-				repoLayers := h.FetchManifestLayers(t, repoName)
-				repoTopLayers = repoLayers[len(oldBaseLayers):]
-			})
+	when("config setters", func() {
+		it("sets the config values", func() {
+			img, err := remote.NewImage(repoName, authn.DefaultKeychain)
+			h.AssertNil(t, err)
 
-			it("switches the base", func() {
-				// Before
-				h.AssertEq(t,
-					h.FetchManifestLayers(t, repoName),
-					append(oldBaseLayers, repoTopLayers...),
-				)
+			h.AssertNil(t, img.SetUser("some-user"))
+			h.AssertNil(t, img.SetExposedPorts(map[string]struct{}{"8080/tcp": {}}))
+			h.AssertNil(t, img.SetVolumes(map[string]struct{}{"/some/volume": {}}))
+			h.AssertNil(t, img.SetHealthcheck(imgutil.HealthConfig{
+				Test:     []string{"CMD", "some-check"},
+				Interval: 30 * time.Second,
+				Retries:  3,
+			}))
+			h.AssertNil(t, img.SetStopSignal("SIGKILL"))
+			h.AssertNil(t, img.SetShell("/bin/bash", "-c"))
+			h.AssertNil(t, img.SetOnBuild("RUN some-trigger"))
 
-				// Run rebase
-				img, err := remote.NewImage(repoName, authn.DefaultKeychain, remote.FromBaseImage(repoName))
-				h.AssertNil(t, err)
-				newBaseImg, err := remote.NewImage(newBase, authn.DefaultKeychain, remote.FromBaseImage(newBase))
-				h.AssertNil(t, err)
-				err = img.Rebase(oldTopLayerDiffID, newBaseImg)
-				h.AssertNil(t, err)
-				h.AssertNil(t, img.Save())
+			h.AssertNil(t, img.Save())
 
-				// After
-				h.AssertEq(t,
-					h.FetchManifestLayers(t, repoName),
-					append(newBaseLayers, repoTopLayers...),
-				)
+			configFile := h.FetchManifestImageConfigFile(t, repoName)
+			h.AssertEq(t, configFile.Config.User, "some-user")
+			h.AssertEq(t, configFile.Config.ExposedPorts, map[string]struct{}{"8080/tcp": {}})
+			h.AssertEq(t, configFile.Config.Volumes, map[string]struct{}{"/some/volume": {}})
+			h.AssertEq(t, configFile.Config.Healthcheck, &v1.HealthConfig{
+				Test:     []string{"CMD", "some-check"},
+				Interval: 30 * time.Second,
+				Retries:  3,
 			})
+			h.AssertEq(t, configFile.Config.StopSignal, "SIGKILL")
+			h.AssertEq(t, configFile.Config.Shell, []string{"/bin/bash", "-c"})
+			h.AssertEq(t, configFile.Config.OnBuild, []string{"RUN some-trigger"})
 		})
 	})
 
-	when("#TopLayer", func() {
-		when("image exists", func() {
-			it("returns the digest for the top layer (useful for rebasing)", func() {
-				baseLayerPath, err := h.CreateSingleFileLayerTar("/old-base.txt", "old-base", "linux")
-				h.AssertNil(t, err)
-				defer os.Remove(baseLayerPath)
-
-				topLayerPath, err := h.CreateSingleFileLayerTar("/top-layer.txt", "top-layer", "linux")
-				h.AssertNil(t, err)
-				defer os.Remove(topLayerPath)
+	when("#RemoveLayer", func() {
+		var (
+			baseImageName   string
+			baseLayerDiffID string
+		)
 
-				expectedTopLayerDiffID := h.FileDiffID(t, topLayerPath)
+		it.Before(func() {
+			baseImageName = newTestImageName()
+			baseImage, err := remote.NewImage(baseImageName, authn.DefaultKeychain)
+			h.AssertNil(t, err)
 
-				existingImage, err := remote.NewImage(repoName, authn.DefaultKeychain, remote.FromBaseImage(repoName))
-				h.AssertNil(t, err)
+			baseLayerPath, err := h.CreateSingleFileLayerTar("/base-layer.txt", "base-layer", "linux")
+			h.AssertNil(t, err)
+			defer os.Remove(baseLayerPath)
+			baseLayerDiffID = h.FileDiffID(t, baseLayerPath)
 
-				err = existingImage.AddLayer(baseLayerPath)
-				h.AssertNil(t, err)
+			h.AssertNil(t, baseImage.AddLayer(baseLayerPath))
+			h.AssertNil(t, baseImage.Save())
+		})
 
-				err = existingImage.AddLayer(topLayerPath)
-				h.AssertNil(t, err)
+		it("removes an added layer", func() {
+			img, err := remote.NewImage(repoName, authn.DefaultKeychain, remote.FromBaseImage(baseImageName))
+			h.AssertNil(t, err)
 
-				h.AssertNil(t, existingImage.Save())
+			layer1Path, err := h.CreateSingleFileLayerTar("/layer-1.txt", "layer-1", "linux")
+			h.AssertNil(t, err)
+			defer os.Remove(layer1Path)
+			layer2Path, err := h.CreateSingleFileLayerTar("/layer-2.txt", "layer-2", "linux")
+			h.AssertNil(t, err)
+			defer os.Remove(layer2Path)
 
-				img, err := remote.NewImage(repoName, authn.DefaultKeychain, remote.FromBaseImage(repoName))
-				h.AssertNil(t, err)
+			h.AssertNil(t, img.AddLayer(layer1Path))
+			h.AssertNil(t, img.AddLayer(layer2Path))
+			h.AssertNil(t, img.RemoveLayer(h.FileDiffID(t, layer1Path)))
 
-				actualTopLayerDiffID, err := img.TopLayer()
-				h.AssertNil(t, err)
+			h.AssertNil(t, img.Save())
 
-				h.AssertEq(t, actualTopLayerDiffID, expectedTopLayerDiffID)
-			})
+			manifestLayerDiffIDs := h.FetchManifestLayers(t, repoName)
+			h.AssertEq(t, manifestLayerDiffIDs, []string{baseLayerDiffID, h.FileDiffID(t, layer2Path)})
 		})
+	})
 
+	when("#TopLayer", func() {
 		when("the image has no layers", func() {
 			it("returns an error", func() {
 				img, err := remote.NewImage(repoName, authn.DefaultKeychain)
@@ -676,98 +709,59 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 		})
 	})
 
-	when("#ReuseLayer", func() {
-		when("previous image", func() {
-			var (
-				prevImageName string
-				prevLayer1SHA string
-				prevLayer2SHA string
-			)
-
-			it.Before(func() {
-				prevImageName = "localhost:" + registryPort + "/pack-image-test-" + h.RandString(10)
-				prevImage, err := remote.NewImage(
-					prevImageName,
-					authn.DefaultKeychain,
-				)
-				h.AssertNil(t, err)
-
-				layer1Path, err := h.CreateSingleFileLayerTar("/layer-1.txt", "old-layer-1", "linux")
-				h.AssertNil(t, err)
-				defer os.Remove(layer1Path)
+	when("#Rebase", func() {
+		it("keeps the compressed layers of the new base for an image of another backend", func() {
+			newBaseLayerPath, err := h.CreateSingleFileLayerTar("/base.txt", "new-base", "linux")
+			h.AssertNil(t, err)
+			defer os.Remove(newBaseLayerPath)
 
-				prevLayer1SHA = h.FileDiffID(t, layer1Path)
+			// compressed differently than layers are compressed when read through GetLayer
+			newBaseLayer, err := ggcrtarball.LayerFromFile(newBaseLayerPath, ggcrtarball.WithCompressionLevel(gzip.BestCompression))
+			h.AssertNil(t, err)
+			newBaseImage, err := mutate.AppendLayers(empty.Image, newBaseLayer)
+			h.AssertNil(t, err)
+			ref, err := name.ParseReference(repoName, name.WeakValidation)
+			h.AssertNil(t, err)
+			h.AssertNil(t, ggcrremote.Write(ref, newBaseImage))
 
-				layer2Path, err := h.CreateSingleFileLayerTar("/layer-2.txt", "old-layer-2", "linux")
-				h.AssertNil(t, err)
-				defer os.Remove(layer2Path)
+			newBase, err := remote.NewImage(repoName, authn.DefaultKeychain, remote.FromBaseImage(repoName))
+			h.AssertNil(t, err)
 
-				prevLayer2SHA = h.FileDiffID(t, layer2Path)
+			tmpDir, err := ioutil.TempDir("", "imgutil.remote.rebase.")
+			h.AssertNil(t, err)
+			defer os.RemoveAll(tmpDir)
 
-				h.AssertNil(t, prevImage.AddLayer(layer1Path))
-				h.AssertNil(t, prevImage.AddLayer(layer2Path))
+			oldBaseLayerPath, err := h.CreateSingleFileLayerTar("/base.txt", "old-base", "linux")
+			h.AssertNil(t, err)
+			defer os.Remove(oldBaseLayerPath)
 
-				h.AssertNil(t, prevImage.Save())
-			})
+			appPath := filepath.Join(tmpDir, "app")
+			img, err := layout.NewImage(appPath)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.AddLayer(oldBaseLayerPath))
+			h.AssertNil(t, img.Rebase(h.FileDiffID(t, oldBaseLayerPath), newBase))
+			h.AssertNil(t, img.Save())
 
-			it("reuses a layer", func() {
-				img, err := remote.NewImage(
-					repoName,
-					authn.DefaultKeychain,
-					remote.WithPreviousImage(prevImageName),
-				)
-				h.AssertNil(t, err)
+			digest, err := newBaseLayer.Digest()
+			h.AssertNil(t, err)
+			_, err = os.Stat(filepath.Join(appPath, "blobs", digest.Algorithm, digest.Hex))
+			h.AssertNil(t, err)
+		})
+	})
 
-				newBaseLayerPath, err := h.CreateSingleFileLayerTar("/new-base.txt", "base-content", "linux")
+	when("#Save", func() {
+		when("image exists", func() {
+			it("can be pulled by digest", func() {
+				img, err := remote.NewImage(repoName, authn.DefaultKeychain)
 				h.AssertNil(t, err)
-				defer os.Remove(newBaseLayerPath)
-
-				h.AssertNil(t, img.AddLayer(newBaseLayerPath))
 
-				err = img.ReuseLayer(prevLayer2SHA)
+				err = img.SetLabel("mykey", "newValue")
 				h.AssertNil(t, err)
 
 				h.AssertNil(t, img.Save())
 
-				manifestLayers := h.FetchManifestLayers(t, repoName)
-
-				newLayer1SHA := manifestLayers[len(manifestLayers)-2]
-				reusedLayer2SHA := manifestLayers[len(manifestLayers)-1]
-
-				h.AssertNotEq(t, prevLayer1SHA, newLayer1SHA)
-				h.AssertEq(t, prevLayer2SHA, reusedLayer2SHA)
-			})
-
-			it("returns error on nonexistent layer", func() {
-				img, err := remote.NewImage(
-					repoName,
-					authn.DefaultKeychain,
-					remote.WithPreviousImage(prevImageName),
-				)
-				h.AssertNil(t, err)
-
-				img.Rename(repoName)
-
-				err = img.ReuseLayer("some-bad-sha")
-
-				h.AssertError(t, err, "previous image did not have layer with diff id 'some-bad-sha'")
-			})
-		})
-	})
-
-	when("#Save", func() {
-		when("image exists", func() {
-			it("can be pulled by digest", func() {
-				img, err := remote.NewImage(repoName, authn.DefaultKeychain)
-				h.AssertNil(t, err)
-
-				err = img.SetLabel("mykey", "newValue")
-				h.AssertNil(t, err)
-
-				h.AssertNil(t, img.Save())
-
-				identifier, err := img.Identifier()
-				h.AssertNil(t, err)
+				identifier, err := img.Identifier()
+				h.AssertNil(t, err)
 
 				testImg, err := remote.NewImage(
 					"test",
@@ -818,18 +812,6 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 				successfulRepoNames = append([]string{repoName}, additionalRepoNames...)
 			)
 
-			it("saves to multiple names", func() {
-				image, err := remote.NewImage(repoName, authn.DefaultKeychain)
-				h.AssertNil(t, err)
-
-				h.AssertNil(t, image.Save(additionalRepoNames...))
-				for _, n := range successfulRepoNames {
-					testImg, err := remote.NewImage(n, authn.DefaultKeychain)
-					h.AssertNil(t, err)
-					h.AssertEq(t, testImg.Found(), true)
-				}
-			})
-
 			when("a single image name fails", func() {
 				it("returns results with errors for those that failed", func() {
 					failingName := newTestImageName() + ":🧨"
@@ -854,6 +836,188 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 					}
 				})
 			})
+
+			when("several names fail while saving concurrently", func() {
+				it("returns the errors in the order of the names", func() {
+					failingNames := []string{newTestImageName() + ":🧨", newTestImageName() + ":💣"}
+
+					image, err := remote.NewImage(repoName, authn.DefaultKeychain, remote.WithSaveConcurrency(3))
+					h.AssertNil(t, err)
+
+					err = image.Save(failingNames[0], additionalRepoNames[1], failingNames[1], additionalRepoNames[2])
+					saveErr, ok := err.(imgutil.SaveError)
+					h.AssertEq(t, ok, true)
+					h.AssertEq(t, len(saveErr.Errors), 2)
+					h.AssertEq(t, saveErr.Errors[0].ImageName, failingNames[0])
+					h.AssertEq(t, saveErr.Errors[1].ImageName, failingNames[1])
+
+					for _, n := range []string{repoName, additionalRepoNames[1], additionalRepoNames[2]} {
+						testImg, err := remote.NewImage(n, authn.DefaultKeychain)
+						h.AssertNil(t, err)
+						h.AssertEq(t, testImg.Found(), true)
+					}
+				})
+			})
+
+			when("names share a repository", func() {
+				var tagRegistry *h.InProcessRegistry
+
+				it.Before(func() {
+					tagRegistry = h.NewInProcessRegistry()
+					tagRegistry.Start(t)
+				})
+
+				it.After(func() {
+					tagRegistry.Stop(t)
+				})
+
+				it("pushes the blobs once and only writes the manifest for the other names", func() {
+					repo := "pack-image-test-" + h.RandString(10)
+					imageName := tagRegistry.Host() + "/" + repo
+
+					layerPath, err := h.CreateSingleFileLayerTar("/layer.txt", "layer", "linux")
+					h.AssertNil(t, err)
+					defer os.Remove(layerPath)
+
+					image, err := remote.NewImage(imageName, authn.DefaultKeychain)
+					h.AssertNil(t, err)
+					h.AssertNil(t, image.AddLayer(layerPath))
+					h.AssertNil(t, image.Save(imageName+":1.0.0", imageName+":some-sha"))
+
+					// one check each for the layer and the config
+					h.AssertEq(t, tagRegistry.BlobChecks(repo), 2)
+
+					identifier, err := image.Identifier()
+					h.AssertNil(t, err)
+					for _, tag := range []string{"latest", "1.0.0", "some-sha"} {
+						testImg, err := remote.NewImage(imageName+":"+tag, authn.DefaultKeychain, remote.FromBaseImage(imageName+":"+tag))
+						h.AssertNil(t, err)
+						testIdentifier, err := testImg.Identifier()
+						h.AssertNil(t, err)
+						h.AssertEq(t, testIdentifier.String(), identifier.String())
+					}
+				})
+			})
+		})
+	})
+
+	when("#WithProgress", func() {
+		it("reports the upload of each layer", func() {
+			layerPath, err := h.CreateSingleFileLayerTar("/layer.txt", "layer", "linux")
+			h.AssertNil(t, err)
+			defer os.Remove(layerPath)
+
+			var (
+				lock    sync.Mutex
+				updates []imgutil.Progress
+			)
+			img, err := remote.NewImage(repoName, authn.DefaultKeychain, remote.WithProgress(func(p imgutil.Progress) {
+				lock.Lock()
+				defer lock.Unlock()
+				updates = append(updates, p)
+			}))
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.AddLayer(layerPath))
+			h.AssertNil(t, img.Save())
+
+			h.AssertEq(t, len(updates) > 0, true)
+			last := updates[len(updates)-1]
+			h.AssertEq(t, last.Complete, true)
+			h.AssertEq(t, last.Transferred, last.Total)
+			h.AssertEq(t, strings.HasPrefix(last.ID, "sha256:"), true)
+		})
+	})
+
+	when("#WithRetry", func() {
+		var (
+			layerPath string
+			policy    = remote.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+		)
+
+		it.Before(func() {
+			var err error
+			layerPath, err = h.CreateSingleFileLayerTar("/layer.txt", "layer", "linux")
+			h.AssertNil(t, err)
+		})
+
+		it.After(func() {
+			os.Remove(layerPath)
+		})
+
+		it("retries reads, uploads, manifest writes and deletes that fail transiently", func() {
+			transport := &flakyTransport{status: http.StatusServiceUnavailable}
+
+			img, err := remote.NewImage(repoName, authn.DefaultKeychain, remote.WithTransport(transport), remote.WithRetry(policy))
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.AddLayer(layerPath))
+			h.AssertNil(t, img.Save())
+
+			saved, err := remote.NewImage(repoName, authn.DefaultKeychain, remote.FromBaseImage(repoName), remote.WithTransport(transport), remote.WithRetry(policy))
+			h.AssertNil(t, err)
+			topLayer, err := saved.TopLayer()
+			h.AssertNil(t, err)
+			h.AssertEq(t, topLayer, h.FileDiffID(t, layerPath))
+
+			h.AssertNil(t, saved.Delete())
+
+			for _, request := range []string{"PATCH", "PUT /v2/" + strings.TrimPrefix(repoName, registryHost+"/") + "/manifests/latest", "DELETE"} {
+				h.AssertEq(t, transport.failedRequest(request), true)
+			}
+		})
+
+		it("retries failed blob uploads in a new upload session", func() {
+			transport := &flakyTransport{status: http.StatusInternalServerError}
+
+			img, err := remote.NewImage(repoName, authn.DefaultKeychain, remote.WithTransport(transport), remote.WithRetry(policy))
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.AddLayer(layerPath))
+			h.AssertNil(t, img.Save())
+
+			h.AssertEq(t, transport.failedRequest("PATCH"), true)
+			h.AssertEq(t, transport.maxSent("PATCH"), 1)
+		})
+
+		it("doesn't wait longer than MaxBackoff when Retry-After asks to", func() {
+			transport := &flakyTransport{status: http.StatusTooManyRequests, retryAfter: "60", maxFailures: 1}
+
+			img, err := remote.NewImage(repoName, authn.DefaultKeychain, remote.WithTransport(transport), remote.WithRetry(policy))
+			h.AssertNil(t, err)
+
+			start := time.Now()
+			h.AssertNil(t, img.Save())
+			h.AssertEq(t, time.Since(start) < 10*time.Second, true)
+		})
+
+		it("waits as long as Retry-After asks", func() {
+			transport := &flakyTransport{status: http.StatusTooManyRequests, retryAfter: "1", maxFailures: 1}
+
+			img, err := remote.NewImage(repoName, authn.DefaultKeychain, remote.WithTransport(transport), remote.WithRetry(remote.RetryPolicy{MaxAttempts: 2}))
+			h.AssertNil(t, err)
+
+			start := time.Now()
+			h.AssertNil(t, img.Save())
+			h.AssertEq(t, time.Since(start) >= time.Second, true)
+		})
+
+		it("fails without retries", func() {
+			transport := &flakyTransport{status: http.StatusServiceUnavailable}
+
+			img, err := remote.NewImage(repoName, authn.DefaultKeychain, remote.WithTransport(transport))
+			h.AssertNil(t, err)
+
+			h.AssertError(t, img.Save(), "failed to write image to the following tags")
+		})
+
+		it("returns an error when the policy allows no attempts", func() {
+			_, err := remote.NewImage(repoName, authn.DefaultKeychain, remote.WithRetry(remote.RetryPolicy{}))
+			h.AssertError(t, err, "retry policy must allow at least 1 attempt, got 0")
+		})
+	})
+
+	when("#WithSaveConcurrency", func() {
+		it("returns an error when it is less than 1", func() {
+			_, err := remote.NewImage(repoName, authn.DefaultKeychain, remote.WithSaveConcurrency(0))
+			h.AssertError(t, err, "save concurrency must be at least 1, got 0")
 		})
 	})
 
@@ -881,6 +1045,495 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 		})
 	})
 
+	when("#Exists", func() {
+		var (
+			headRegistry *h.InProcessRegistry
+			headRepo     string
+		)
+
+		it.Before(func() {
+			headRegistry = h.NewInProcessRegistry()
+			headRegistry.Start(t)
+			headRepo = "pack-image-test-" + h.RandString(10)
+			repoName = headRegistry.Host() + "/" + headRepo
+		})
+
+		it.After(func() {
+			headRegistry.Stop(t)
+		})
+
+		it("returns true without fetching the manifest when the image exists", func() {
+			origImage, err := remote.NewImage(repoName, authn.DefaultKeychain)
+			h.AssertNil(t, err)
+			h.AssertNil(t, origImage.Save())
+
+			image, err := remote.NewImage(repoName, authn.DefaultKeychain)
+			h.AssertNil(t, err)
+
+			exists, err := image.(*remote.Image).Exists()
+			h.AssertNil(t, err)
+			h.AssertEq(t, exists, true)
+			h.AssertEq(t, headRegistry.ManifestFetches(headRepo), 0)
+		})
+
+		it("returns false when the image doesn't exist", func() {
+			image, err := remote.NewImage(repoName, authn.DefaultKeychain)
+			h.AssertNil(t, err)
+
+			exists, err := image.(*remote.Image).Exists()
+			h.AssertNil(t, err)
+			h.AssertEq(t, exists, false)
+		})
+
+		it("returns an error when the registry can't be reached", func() {
+			image, err := remote.NewImage("localhost:1/some-repo", authn.DefaultKeychain)
+			h.AssertNil(t, err)
+
+			_, err = image.(*remote.Image).Exists()
+			h.AssertError(t, err, "connection refused")
+			h.AssertEq(t, image.Found(), false)
+		})
+	})
+
+	when("#RemoteDigest", func() {
+		var (
+			headRegistry *h.InProcessRegistry
+			headRepo     string
+		)
+
+		it.Before(func() {
+			headRegistry = h.NewInProcessRegistry()
+			headRegistry.Start(t)
+			headRepo = "pack-image-test-" + h.RandString(10)
+			repoName = headRegistry.Host() + "/" + headRepo
+		})
+
+		it.After(func() {
+			headRegistry.Stop(t)
+		})
+
+		it("returns the digest of the saved manifest without fetching it", func() {
+			origImage, err := remote.NewImage(repoName, authn.DefaultKeychain)
+			h.AssertNil(t, err)
+			h.AssertNil(t, origImage.Save())
+			identifier, err := origImage.Identifier()
+			h.AssertNil(t, err)
+
+			image, err := remote.NewImage(repoName, authn.DefaultKeychain)
+			h.AssertNil(t, err)
+
+			digest, err := image.(*remote.Image).RemoteDigest()
+			h.AssertNil(t, err)
+			h.AssertEq(t, repoName+"@"+digest.String(), identifier.String())
+			h.AssertEq(t, headRegistry.ManifestFetches(headRepo), 0)
+		})
+
+		it("returns an error when the image doesn't exist", func() {
+			image, err := remote.NewImage(repoName, authn.DefaultKeychain)
+			h.AssertNil(t, err)
+
+			_, err = image.(*remote.Image).RemoteDigest()
+			h.AssertEq(t, errors.Is(err, imgutil.ErrImageNotFound), true)
+		})
+	})
+
+	when("the registry requires authentication", func() {
+		for _, tc := range []struct {
+			name string
+			op   h.RegistryOption
+		}{
+			{"basic auth", h.WithBasicAuth("some-user", "some-password")},
+			{"token auth", h.WithTokenAuth("some-user", "some-password")},
+		} {
+			tc := tc
+
+			when(tc.name, func() {
+				var (
+					authRegistry *h.InProcessRegistry
+					authRepoName string
+				)
+
+				it.Before(func() {
+					authRegistry = h.NewInProcessRegistry(tc.op)
+					authRegistry.Start(t)
+					authRepoName = authRegistry.Host() + "/pack-image-test-" + h.RandString(10)
+				})
+
+				it.After(func() {
+					authRegistry.Stop(t)
+				})
+
+				it("saves and reads images with valid credentials", func() {
+					keychain := staticKeychain{&authn.Basic{Username: "some-user", Password: "some-password"}}
+
+					img, err := remote.NewImage(authRepoName, keychain)
+					h.AssertNil(t, err)
+					h.AssertNil(t, img.SetLabel("mykey", "my-val"))
+					h.AssertNil(t, img.Save())
+
+					saved, err := remote.NewImage(authRepoName, keychain, remote.FromBaseImage(authRepoName))
+					h.AssertNil(t, err)
+					h.AssertEq(t, saved.Found(), true)
+
+					label, err := saved.Label("mykey")
+					h.AssertNil(t, err)
+					h.AssertEq(t, label, "my-val")
+				})
+
+				it("fails to save with invalid credentials", func() {
+					keychain := staticKeychain{&authn.Basic{Username: "some-user", Password: "wrong-password"}}
+
+					img, err := remote.NewImage(authRepoName, keychain)
+					h.AssertNil(t, err)
+
+					h.AssertError(t, img.Save(), "failed to write image to the following tags")
+				})
+
+				it("starts from an empty base image with invalid credentials unless strict", func() {
+					keychain := staticKeychain{&authn.Basic{Username: "some-user", Password: "some-password"}}
+					img, err := remote.NewImage(authRepoName, keychain)
+					h.AssertNil(t, err)
+					h.AssertNil(t, img.Save())
+
+					keychain = staticKeychain{&authn.Basic{Username: "some-user", Password: "wrong-password"}}
+					img, err = remote.NewImage(newTestImageName(), keychain, remote.FromBaseImage(authRepoName))
+					h.AssertNil(t, err)
+					h.AssertEq(t, img.(*remote.Image).BaseImageFound(), false)
+
+					_, err = remote.NewImage(newTestImageName(), keychain, remote.FromBaseImage(authRepoName, remote.Strict()))
+					h.AssertEq(t, errors.Is(err, imgutil.ErrUnauthorized), true)
+				})
+
+				it("reports invalid credentials from exists checks", func() {
+					keychain := staticKeychain{&authn.Basic{Username: "some-user", Password: "wrong-password"}}
+					img, err := remote.NewImage(authRepoName, keychain)
+					h.AssertNil(t, err)
+
+					_, err = img.(*remote.Image).Exists()
+					h.AssertEq(t, errors.Is(err, imgutil.ErrUnauthorized), true)
+				})
+			})
+		}
+	})
+
+	when("layers come from another repository on the same registry", func() {
+		var (
+			mountRegistry *h.InProcessRegistry
+			layerPath     string
+			baseRepo      string
+			appRepo       string
+		)
+
+		startRegistry := func(ops ...h.RegistryOption) {
+			mountRegistry = h.NewInProcessRegistry(ops...)
+			mountRegistry.Start(t)
+			baseRepo = "pack-image-test-" + h.RandString(10)
+			appRepo = "pack-image-test-" + h.RandString(10)
+
+			baseImage, err := remote.NewImage(mountRegistry.Host()+"/"+baseRepo, authn.DefaultKeychain)
+			h.AssertNil(t, err)
+			h.AssertNil(t, baseImage.AddLayer(layerPath))
+			h.AssertNil(t, baseImage.Save())
+		}
+
+		it.Before(func() {
+			var err error
+			layerPath, err = h.CreateSingleFileLayerTar("/base.txt", "base", "linux")
+			h.AssertNil(t, err)
+		})
+
+		it.After(func() {
+			mountRegistry.Stop(t)
+			os.Remove(layerPath)
+		})
+
+		it("mounts base image layers instead of uploading them", func() {
+			startRegistry()
+
+			img, err := remote.NewImage(mountRegistry.Host()+"/"+appRepo, authn.DefaultKeychain, remote.FromBaseImage(mountRegistry.Host()+"/"+baseRepo))
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.SetLabel("mykey", "my-val"))
+			h.AssertNil(t, img.Save())
+
+			h.AssertEq(t, mountRegistry.BlobMounts(appRepo), 1)
+			// only the config is uploaded
+			h.AssertEq(t, mountRegistry.BlobUploads(appRepo), 1)
+		})
+
+		it("mounts reused previous image layers instead of uploading them", func() {
+			startRegistry()
+
+			img, err := remote.NewImage(mountRegistry.Host()+"/"+appRepo, authn.DefaultKeychain, remote.WithPreviousImage(mountRegistry.Host()+"/"+baseRepo))
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.ReuseLayer(h.FileDiffID(t, layerPath)))
+			h.AssertNil(t, img.Save())
+
+			h.AssertEq(t, mountRegistry.BlobMounts(appRepo), 1)
+			h.AssertEq(t, mountRegistry.BlobUploads(appRepo), 1)
+		})
+
+		it("uploads the layers when the registry refuses to mount them", func() {
+			startRegistry(h.WithRefusedMounts())
+
+			appRepoName := mountRegistry.Host() + "/" + appRepo
+			img, err := remote.NewImage(appRepoName, authn.DefaultKeychain, remote.FromBaseImage(mountRegistry.Host()+"/"+baseRepo))
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.Save())
+
+			h.AssertEq(t, mountRegistry.BlobMounts(appRepo), 0)
+			h.AssertEq(t, mountRegistry.BlobUploads(appRepo), 2)
+
+			saved, err := remote.NewImage(appRepoName, authn.DefaultKeychain, remote.FromBaseImage(appRepoName))
+			h.AssertNil(t, err)
+			topLayer, err := saved.TopLayer()
+			h.AssertNil(t, err)
+			h.AssertEq(t, topLayer, h.FileDiffID(t, layerPath))
+		})
+
+		it("doesn't upload the layers when the registry refuses another request", func() {
+			startRegistry()
+
+			refusing := &refusingTransport{method: http.MethodPut, pathPart: "/manifests/", status: http.StatusForbidden}
+			img, err := remote.NewImage(mountRegistry.Host()+"/"+appRepo, authn.DefaultKeychain,
+				remote.FromBaseImage(mountRegistry.Host()+"/"+baseRepo),
+				remote.WithTransport(refusing),
+			)
+			h.AssertNil(t, err)
+
+			err = img.Save()
+			h.AssertError(t, err, "403")
+			h.AssertEq(t, refusing.refusedCount(), 1)
+			h.AssertEq(t, mountRegistry.BlobMounts(appRepo), 1)
+		})
+	})
+
+	when("#WithRegistryMirrors", func() {
+		var (
+			mirrorRegistry *h.InProcessRegistry
+			repoPath       string
+		)
+
+		saveImage := func(imageName, label string) {
+			img, err := remote.NewImage(imageName, authn.DefaultKeychain)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.SetLabel("source", label))
+			h.AssertNil(t, img.Save())
+		}
+
+		assertSource := func(img imgutil.Image, label string) {
+			val, err := img.Label("source")
+			h.AssertNil(t, err)
+			h.AssertEq(t, val, label)
+		}
+
+		it.Before(func() {
+			mirrorRegistry = h.NewInProcessRegistry()
+			mirrorRegistry.Start(t)
+			repoPath = "pack-image-test-" + h.RandString(10)
+			repoName = registryHost + "/" + repoPath + ":some-tag"
+			saveImage(repoName, "canonical")
+		})
+
+		it.After(func() {
+			mirrorRegistry.Stop(t)
+		})
+
+		it("reads the base image from a mirror that has it", func() {
+			mirrorName := mirrorRegistry.Host() + "/" + repoPath + ":some-tag"
+			saveImage(mirrorName, "mirror")
+
+			img, err := remote.NewImage(newTestImageName(), authn.DefaultKeychain,
+				remote.FromBaseImage(repoName),
+				remote.WithRegistryMirrors(registryHost, "localhost:1", mirrorRegistry.Host()),
+			)
+			h.AssertNil(t, err)
+
+			assertSource(img, "mirror")
+			h.AssertEq(t, img.(*remote.Image).BaseImageSource(), mirrorName)
+		})
+
+		it("supports mirrors that nest repositories under a path", func() {
+			mirrorName := mirrorRegistry.Host() + "/some/path/" + repoPath + ":some-tag"
+			saveImage(mirrorName, "mirror")
+
+			img, err := remote.NewImage(newTestImageName(), authn.DefaultKeychain,
+				remote.FromBaseImage(repoName),
+				remote.WithRegistryMirrors(registryHost, mirrorRegistry.Host()+"/some/path"),
+			)
+			h.AssertNil(t, err)
+
+			assertSource(img, "mirror")
+			h.AssertEq(t, img.(*remote.Image).BaseImageSource(), mirrorName)
+		})
+
+		it("reads the previous image from a mirror that has it", func() {
+			mirrorName := mirrorRegistry.Host() + "/" + repoPath + ":some-tag"
+			saveImage(mirrorName, "mirror")
+
+			img, err := remote.NewImage(newTestImageName(), authn.DefaultKeychain,
+				remote.WithPreviousImage(repoName),
+				remote.WithRegistryMirrors(registryHost, mirrorRegistry.Host()),
+			)
+			h.AssertNil(t, err)
+
+			h.AssertEq(t, img.(*remote.Image).PreviousImageSource(), mirrorName)
+		})
+
+		it("falls back to the registry when no mirror has the image", func() {
+			img, err := remote.NewImage(newTestImageName(), authn.DefaultKeychain,
+				remote.FromBaseImage(repoName),
+				remote.WithRegistryMirrors(registryHost, "localhost:1", mirrorRegistry.Host()),
+			)
+			h.AssertNil(t, err)
+
+			assertSource(img, "canonical")
+			h.AssertEq(t, img.(*remote.Image).BaseImageSource(), repoName)
+		})
+
+		it("has no source when the image doesn't exist", func() {
+			img, err := remote.NewImage(newTestImageName(), authn.DefaultKeychain,
+				remote.FromBaseImage(newTestImageName()),
+				remote.WithRegistryMirrors(registryHost, mirrorRegistry.Host()),
+			)
+			h.AssertNil(t, err)
+
+			h.AssertEq(t, img.(*remote.Image).BaseImageSource(), "")
+		})
+
+		it("returns an error for an invalid mirror", func() {
+			_, err := remote.NewImage(repoName, authn.DefaultKeychain, remote.WithRegistryMirrors("docker.io", "Not A Mirror"))
+			h.AssertError(t, err, "invalid mirror 'Not A Mirror'")
+		})
+	})
+
+	when("#Strict", func() {
+		it.Before(func() {
+			img, err := remote.NewImage(repoName, authn.DefaultKeychain)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.Save())
+		})
+
+		it("reads base and previous images that exist", func() {
+			img, err := remote.NewImage(newTestImageName(), authn.DefaultKeychain,
+				remote.FromBaseImage(repoName, remote.Strict()),
+				remote.WithPreviousImage(repoName, remote.Strict()),
+			)
+			h.AssertNil(t, err)
+
+			h.AssertEq(t, img.(*remote.Image).BaseImageFound(), true)
+			h.AssertEq(t, img.(*remote.Image).PreviousImageFound(), true)
+		})
+
+		it("returns an error when the base image doesn't exist", func() {
+			_, err := remote.NewImage(newTestImageName(), authn.DefaultKeychain,
+				remote.FromBaseImage(newTestImageName(), remote.Strict()),
+			)
+			h.AssertEq(t, errors.Is(err, imgutil.ErrImageNotFound), true)
+		})
+
+		it("returns an error when the previous image doesn't exist", func() {
+			_, err := remote.NewImage(newTestImageName(), authn.DefaultKeychain,
+				remote.WithPreviousImage(newTestImageName(), remote.Strict()),
+			)
+			h.AssertEq(t, errors.Is(err, imgutil.ErrImageNotFound), true)
+		})
+
+		it("reports images that don't exist as not found otherwise", func() {
+			img, err := remote.NewImage(newTestImageName(), authn.DefaultKeychain,
+				remote.FromBaseImage(newTestImageName()),
+				remote.WithPreviousImage(newTestImageName()),
+			)
+			h.AssertNil(t, err)
+
+			h.AssertEq(t, img.(*remote.Image).BaseImageFound(), false)
+			h.AssertEq(t, img.(*remote.Image).PreviousImageFound(), false)
+		})
+	})
+
+	when("the registry uses TLS", func() {
+		var (
+			tlsRegistry *h.InProcessRegistry
+			tlsRepoName string
+		)
+
+		saveAndRead := func(ops ...remote.ImageOption) {
+			img, err := remote.NewImage(tlsRepoName, authn.DefaultKeychain, ops...)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.SetLabel("mykey", "my-val"))
+			h.AssertNil(t, img.Save())
+
+			saved, err := remote.NewImage(tlsRepoName, authn.DefaultKeychain, append(ops, remote.FromBaseImage(tlsRepoName))...)
+			h.AssertNil(t, err)
+			h.AssertEq(t, saved.Found(), true)
+			label, err := saved.Label("mykey")
+			h.AssertNil(t, err)
+			h.AssertEq(t, label, "my-val")
+
+			h.AssertNil(t, saved.Delete())
+			h.AssertEq(t, saved.Found(), false)
+		}
+
+		when("its certificate isn't trusted by the system", func() {
+			it.Before(func() {
+				tlsRegistry = h.NewInProcessRegistry(h.WithTLS())
+				tlsRegistry.Start(t)
+				tlsRepoName = tlsRegistry.Host() + "/pack-image-test-" + h.RandString(10)
+			})
+
+			it.After(func() {
+				tlsRegistry.Stop(t)
+			})
+
+			it("fails to save", func() {
+				img, err := remote.NewImage(tlsRepoName, authn.DefaultKeychain)
+				h.AssertNil(t, err)
+
+				h.AssertError(t, img.Save(), "failed to write image to the following tags")
+			})
+
+			it("saves and reads images with a CA bundle", func() {
+				saveAndRead(remote.WithCABundle(tlsRegistry.CACertificate()))
+			})
+
+			it("saves and reads images when the registry is insecure", func() {
+				saveAndRead(remote.WithInsecureRegistries(tlsRegistry.Host()))
+			})
+		})
+
+		when("it requires a client certificate", func() {
+			it.Before(func() {
+				tlsRegistry = h.NewInProcessRegistry(h.WithClientCertificates())
+				tlsRegistry.Start(t)
+				tlsRepoName = tlsRegistry.Host() + "/pack-image-test-" + h.RandString(10)
+			})
+
+			it.After(func() {
+				tlsRegistry.Stop(t)
+			})
+
+			it("saves and reads images with a client certificate", func() {
+				saveAndRead(remote.WithCABundle(tlsRegistry.CACertificate()), remote.WithClientCertificate(tlsRegistry.ClientCertificate()))
+			})
+
+			it("fails to save without a client certificate", func() {
+				img, err := remote.NewImage(tlsRepoName, authn.DefaultKeychain, remote.WithCABundle(tlsRegistry.CACertificate()))
+				h.AssertNil(t, err)
+
+				h.AssertError(t, img.Save(), "failed to write image to the following tags")
+			})
+		})
+
+		it("returns an error for a CA bundle without certificates", func() {
+			_, err := remote.NewImage(repoName, authn.DefaultKeychain, remote.WithCABundle([]byte("not a certificate")))
+			h.AssertError(t, err, "CA bundle contains no PEM encoded certificates")
+		})
+
+		it("returns an error when the transport isn't an *http.Transport", func() {
+			_, err := remote.NewImage(repoName, authn.DefaultKeychain, remote.WithTransport(&flakyTransport{}), remote.WithInsecureRegistries(registryHost))
+			h.AssertError(t, err, "require the transport to be an *http.Transport")
+		})
+	})
+
 	when("#Delete", func() {
 		when("it exists", func() {
 			var img imgutil.Image
@@ -911,8 +1564,161 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 				h.AssertNil(t, err)
 
 				h.AssertEq(t, img.Found(), false)
-				h.AssertError(t, img.Delete(), "MANIFEST_UNKNOWN")
+				err = img.Delete()
+				h.AssertError(t, err, "MANIFEST_UNKNOWN")
+				h.AssertEq(t, errors.Is(err, imgutil.ErrImageNotFound), true)
 			})
 		})
 	})
 }
+
+func pushManifestList(t *testing.T, repoName string, platforms ...v1.Platform) {
+	t.Helper()
+
+	ref, err := name.ParseReference(repoName, name.WeakValidation)
+	h.AssertNil(t, err)
+
+	var adds []mutate.IndexAddendum
+	for _, platform := range platforms {
+		platform := platform
+
+		img, err := random.Image(1024, 1)
+		h.AssertNil(t, err)
+
+		cfg, err := img.ConfigFile()
+		h.AssertNil(t, err)
+		cfg = cfg.DeepCopy()
+		cfg.OS = platform.OS
+		cfg.Architecture = platform.Architecture
+		cfg.Config.Labels = map[string]string{"platform": strings.TrimSuffix(platform.OS+"/"+platform.Architecture+"/"+platform.Variant, "/")}
+		img, err = mutate.ConfigFile(img, cfg)
+		h.AssertNil(t, err)
+
+		adds = append(adds, mutate.IndexAddendum{
+			Add: img,
+			Descriptor: v1.Descriptor{
+				Platform: &platform,
+			},
+		})
+	}
+
+	index := mutate.IndexMediaType(mutate.AppendManifests(empty.Index, adds...), types.DockerManifestList)
+	h.AssertNil(t, ggcrremote.WriteIndex(ref, index))
+}
+
+// flakyTransport fails the first request for every method and path with status, up to maxFailures requests when it is set.
+// Requests to any blob upload session of a repository count as requests to the same path, as a failed upload is
+// retried in a new session.
+type flakyTransport struct {
+	status      int
+	retryAfter  string
+	maxFailures int
+
+	lock   sync.Mutex
+	failed map[string]bool
+	sent   map[string]int
+}
+
+var uploadSessionPath = regexp.MustCompile(`/blobs/uploads/.+$`)
+
+func (t *flakyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	request := req.Method + " " + uploadSessionPath.ReplaceAllString(req.URL.Path, "/blobs/uploads/")
+
+	t.lock.Lock()
+	if t.failed == nil {
+		t.failed = map[string]bool{}
+		t.sent = map[string]int{}
+	}
+	t.sent[req.Method+" "+req.URL.Path]++
+	fail := !t.failed[request] && (t.maxFailures == 0 || len(t.failed) < t.maxFailures)
+	if fail {
+		t.failed[request] = true
+	}
+	t.lock.Unlock()
+
+	if !fail {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	if req.Body != nil {
+		_, _ = io.Copy(ioutil.Discard, req.Body)
+		req.Body.Close()
+	}
+	header := http.Header{}
+	if t.retryAfter != "" {
+		header.Set("Retry-After", t.retryAfter)
+	}
+	return &http.Response{
+		StatusCode: t.status,
+		Status:     http.StatusText(t.status),
+		Header:     header,
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}, nil
+}
+
+// maxSent returns how many times the request starting with prefix that was sent the most was sent.
+func (t *flakyTransport) maxSent(prefix string) int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	max := 0
+	for request, n := range t.sent {
+		if strings.HasPrefix(request, prefix) && n > max {
+			max = n
+		}
+	}
+	return max
+}
+
+// failedRequest returns whether a request starting with prefix was failed.
+func (t *flakyTransport) failedRequest(prefix string) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for request := range t.failed {
+		if strings.HasPrefix(request, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+type staticKeychain struct {
+	auth authn.Authenticator
+}
+
+func (k staticKeychain) Resolve(authn.Resource) (authn.Authenticator, error) {
+	return k.auth, nil
+}
+
+// refusingTransport answers requests with method to paths containing pathPart with status.
+type refusingTransport struct {
+	method   string
+	pathPart string
+	status   int
+
+	lock    sync.Mutex
+	refused int
+}
+
+func (t *refusingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != t.method || !strings.Contains(req.URL.Path, t.pathPart) {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	t.lock.Lock()
+	t.refused++
+	t.lock.Unlock()
+	return &http.Response{
+		StatusCode: t.status,
+		Status:     http.StatusText(t.status),
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}, nil
+}
+
+func (t *refusingTransport) refusedCount() int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.refused
+}
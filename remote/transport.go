@@ -0,0 +1,237 @@
+package remote
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// contextTransport attaches ctx to every request, go-containerregistry doesn't accept a context itself.
+type contextTransport struct {
+	ctx   context.Context
+	inner http.RoundTripper
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.inner.RoundTrip(req.WithContext(t.ctx))
+}
+
+func newTransport(ctx context.Context, inner http.RoundTripper) http.RoundTripper {
+	return &contextTransport{ctx: ctx, inner: inner}
+}
+
+// registryTransport sends requests for insecure registries through a transport that doesn't verify certificates.
+type registryTransport struct {
+	secure             http.RoundTripper
+	insecure           http.RoundTripper
+	insecureRegistries map[string]bool
+}
+
+func (t *registryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.insecureRegistries[req.URL.Host] {
+		return t.insecure.RoundTrip(req)
+	}
+	return t.secure.RoundTrip(req)
+}
+
+// newTLSTransport returns a copy of inner that trusts the certificates in caBundles, presents clientCerts
+// and doesn't verify the certificates of insecureRegistries.
+func newTLSTransport(inner http.RoundTripper, caBundles [][]byte, clientCerts []tls.Certificate, insecureRegistries map[string]bool) (http.RoundTripper, error) {
+	base, ok := inner.(*http.Transport)
+	if !ok {
+		return nil, errors.New("CA bundles, client certificates and insecure registries require the transport to be an *http.Transport")
+	}
+
+	secure := base.Clone()
+	if secure.TLSClientConfig == nil {
+		secure.TLSClientConfig = &tls.Config{}
+	}
+	if len(caBundles) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		for _, bundle := range caBundles {
+			pool.AppendCertsFromPEM(bundle)
+		}
+		secure.TLSClientConfig.RootCAs = pool
+	}
+	secure.TLSClientConfig.Certificates = append(secure.TLSClientConfig.Certificates, clientCerts...)
+
+	insecure := secure.Clone()
+	insecure.TLSClientConfig.InsecureSkipVerify = true
+
+	return &registryTransport{secure: secure, insecure: insecure, insecureRegistries: insecureRegistries}, nil
+}
+
+// RetryPolicy configures how registry requests that fail with a connection error, a 429 or a 5xx status are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the number of times a request is made, including the first attempt.
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry, it doubles with every retry after that.
+	InitialBackoff time.Duration
+	// MaxBackoff limits the wait between attempts, including waits asked for by a Retry-After header.
+	// When it is zero, the MaxBackoff of DefaultRetryPolicy applies.
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy makes up to five attempts, waiting up to 30 seconds between them.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: time.Second,
+	MaxBackoff:     30 * time.Second,
+}
+
+// backoff returns how long to wait before the given retry, the first being 1, or as long as retryAfter asks when that
+// is longer.
+func (p RetryPolicy) backoff(retry int, retryAfter time.Duration) time.Duration {
+	wait := p.InitialBackoff
+	for n := 1; n < retry; n++ {
+		wait *= 2
+	}
+	if retryAfter > wait {
+		wait = retryAfter
+	}
+	maxBackoff := p.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultRetryPolicy.MaxBackoff
+	}
+	if wait > maxBackoff {
+		wait = maxBackoff
+	}
+	return wait
+}
+
+// retryTransport retries requests according to policy when sending them again is safe, see canResend.
+// Blob uploads are retried by writing the image again instead, see Image.write.
+type retryTransport struct {
+	inner  http.RoundTripper
+	policy RetryPolicy
+}
+
+func newRetryTransport(inner http.RoundTripper, policy RetryPolicy) http.RoundTripper {
+	return &retryTransport{inner: inner, policy: policy}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !canResend(req) {
+		return t.inner.RoundTrip(req)
+	}
+
+	for attempt := 1; ; attempt++ {
+		attemptReq := req
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq = req.Clone(req.Context())
+			attemptReq.Body = body
+		}
+
+		resp, err := t.inner.RoundTrip(attemptReq)
+		if attempt >= t.policy.MaxAttempts || !shouldRetry(req, resp, err) {
+			return resp, err
+		}
+
+		var retryAfter time.Duration
+		if resp != nil {
+			retryAfter, _ = parseRetryAfter(resp.Header.Get("Retry-After"))
+			drain(resp)
+		}
+		if err := sleep(req.Context(), t.policy.backoff(attempt, retryAfter)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// canResend tells whether req can be sent again, i.e. its body can be read again and it doesn't append to a blob
+// upload session, which registries don't accept twice.
+func canResend(req *http.Request) bool {
+	if req.Method == http.MethodPatch {
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+func shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if err != nil {
+		return req.Context().Err() == nil
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
+
+// sleep waits for d, or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// writeTransport records what went wrong while writing an image that Image.write and Image.doSave can recover from,
+// i.e. a blob upload that failed transiently and a blob mount that the registry refused.
+type writeTransport struct {
+	inner http.RoundTripper
+
+	lock         sync.Mutex
+	uploadFailed bool
+	mountRefused bool
+}
+
+func (t *writeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.inner.RoundTrip(req)
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if !canResend(req) && shouldRetry(req, resp, err) {
+		t.uploadFailed = true
+	}
+	if err == nil && req.Method == http.MethodPost && req.URL.Query().Get("mount") != "" && isRefusal(resp.StatusCode) {
+		t.mountRefused = true
+	}
+	return resp, err
+}
+
+func (t *writeTransport) failedUpload() bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.uploadFailed
+}
+
+func (t *writeTransport) refusedMount() bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.mountRefused
+}
+
+// parseRetryAfter parses a Retry-After header given either in seconds or as an HTTP date.
+func parseRetryAfter(header string) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		return time.Until(date), true
+	}
+	return 0, false
+}
+
+func drain(resp *http.Response) {
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+}
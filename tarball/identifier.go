@@ -0,0 +1,10 @@
+package tarball
+
+// IDIdentifier identifies an image by the digest of its config, which is the ID `docker load` assigns to it.
+type IDIdentifier struct {
+	ImageID string
+}
+
+func (i IDIdentifier) String() string {
+	return i.ImageID
+}
@@ -0,0 +1,349 @@
+package tarball
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/pkg/errors"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/internal/v1image"
+)
+
+type Image struct {
+	v1image.Image
+	path     string
+	prevPath string
+	repoTags []string
+}
+
+type ImageOption func(*Image) (*Image, error)
+
+func WithPreviousImage(path string) ImageOption {
+	return func(i *Image) (*Image, error) {
+		prevImage, err := newV1Image(path)
+		if err != nil {
+			return nil, err
+		}
+
+		prevLayers, err := prevImage.Layers()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get layers for previous image at path '%s'", path)
+		}
+
+		i.PrevLayers = prevLayers
+		i.prevPath = path
+		return i, nil
+	}
+}
+
+// WithRepoTags sets the tags recorded in saved archives, which `docker load` applies to the loaded image.
+func WithRepoTags(tags ...string) ImageOption {
+	return func(i *Image) (*Image, error) {
+		for _, tag := range tags {
+			t, err := name.NewTag(tag, name.WeakValidation)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid repo tag '%s'", tag)
+			}
+			i.repoTags = append(i.repoTags, t.Name())
+		}
+		return i, nil
+	}
+}
+
+func FromBaseImage(path string) ImageOption {
+	return func(i *Image) (*Image, error) {
+		var err error
+
+		i.V1Image, err = newV1Image(path)
+		if err != nil {
+			return nil, err
+		}
+		return i, nil
+	}
+}
+
+// NewImage returns an image that is saved to a `docker save` archive at path.
+func NewImage(path string, ops ...ImageOption) (imgutil.Image, error) {
+	image, err := v1image.Empty(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	li := &Image{
+		Image: v1image.Image{V1Image: image},
+		path:  path,
+	}
+	li.Describe = func() string {
+		return fmt.Sprintf("image at path '%s'", li.path)
+	}
+
+	for _, op := range ops {
+		li, err = op(li)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if li.BaseLayers, err = v1image.LayerCount(li.V1Image); err != nil {
+		return nil, err
+	}
+
+	return li, nil
+}
+
+func newV1Image(path string) (v1.Image, error) {
+	if !isArchive(path) {
+		return v1image.Empty(nil)
+	}
+
+	image, err := tarball.ImageFromPath(path, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read image archive at path '%s'", path)
+	}
+	return image, nil
+}
+
+func isArchive(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.Mode().IsRegular()
+}
+
+func (i *Image) Rename(name string) {
+	i.path = name
+}
+
+func (i *Image) Name() string {
+	return i.path
+}
+
+func (i *Image) Found() bool {
+	return isArchive(i.path)
+}
+
+func (i *Image) Identifier() (imgutil.Identifier, error) {
+	hash, err := i.V1Image.ConfigName()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config digest for image at path '%s': %s", i.path, err)
+	}
+
+	return IDIdentifier{
+		ImageID: hash.String(),
+	}, nil
+}
+
+func (i *Image) Rebase(baseTopLayer string, newBase imgutil.Image, ops ...imgutil.RebaseOption) error {
+	return i.RebaseContext(context.Background(), baseTopLayer, newBase, ops...)
+}
+
+func (i *Image) RebaseContext(ctx context.Context, baseTopLayer string, newBase imgutil.Image, ops ...imgutil.RebaseOption) error {
+	return i.RebaseOnto(ctx, i, baseTopLayer, newBase, ops...)
+}
+
+func (i *Image) Save(additionalNames ...string) error {
+	return i.SaveContext(context.Background(), additionalNames...)
+}
+
+func (i *Image) SaveContext(ctx context.Context, additionalNames ...string) error {
+	var err error
+
+	allNames := append([]string{i.path}, additionalNames...)
+
+	if err := i.Normalize(); err != nil {
+		return err
+	}
+
+	// every archive is written before any is renamed into place, as layers of the image may be read from an archive
+	// being replaced
+	var diagnostics []imgutil.SaveDiagnostic
+	tmpPaths := make([]string, len(allNames))
+	defer func() {
+		for _, tmpPath := range tmpPaths {
+			if tmpPath != "" {
+				os.Remove(tmpPath)
+			}
+		}
+	}()
+	for idx, n := range allNames {
+		if err := ctx.Err(); err != nil {
+			diagnostics = append(diagnostics, imgutil.SaveDiagnostic{ImageName: n, Cause: err})
+			continue
+		}
+		if tmpPaths[idx], err = i.writeTempArchive(n); err != nil {
+			diagnostics = append(diagnostics, imgutil.SaveDiagnostic{ImageName: n, Cause: err})
+		}
+	}
+	if len(diagnostics) > 0 {
+		return imgutil.SaveError{Errors: diagnostics}
+	}
+
+	for idx, n := range allNames {
+		if err := os.Rename(tmpPaths[idx], n); err != nil {
+			diagnostics = append(diagnostics, imgutil.SaveDiagnostic{ImageName: n, Cause: err})
+			continue
+		}
+		tmpPaths[idx] = ""
+	}
+	if len(diagnostics) > 0 {
+		return imgutil.SaveError{Errors: diagnostics}
+	}
+
+	return i.reopen(allNames)
+}
+
+// writeTempArchive writes the archive to a temporary file next to path and returns the path of the file.
+func (i *Image) writeTempArchive(path string) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp.")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if err := i.writeArchive(f); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// reopen reads the image and the previous image from the saved archives, as the archives they were read from may
+// have been replaced by saving.
+func (i *Image) reopen(savedPaths []string) error {
+	image, err := newV1Image(i.path)
+	if err != nil {
+		return err
+	}
+	i.V1Image = image
+
+	if i.prevPath == "" || !containsPath(savedPaths, i.prevPath) {
+		return nil
+	}
+	prevImage, err := newV1Image(i.prevPath)
+	if err != nil {
+		return err
+	}
+	if i.PrevLayers, err = prevImage.Layers(); err != nil {
+		return errors.Wrapf(err, "failed to get layers for previous image at path '%s'", i.prevPath)
+	}
+	return nil
+}
+
+func containsPath(paths []string, path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	for _, p := range paths {
+		if pAbs, err := filepath.Abs(p); err == nil && pAbs == abs {
+			return true
+		}
+	}
+	return false
+}
+
+// writeArchive writes the image in the format produced by `docker save`, except that layers are gzipped and named by
+// digest, which `docker load` accepts as well.
+func (i *Image) writeArchive(w io.Writer) error {
+	tw := tar.NewWriter(w)
+
+	configFile, err := i.V1Image.RawConfigFile()
+	if err != nil {
+		return errors.Wrap(err, "get image config")
+	}
+	configName, err := i.V1Image.ConfigName()
+	if err != nil {
+		return errors.Wrap(err, "get image config digest")
+	}
+	configPath := configName.Hex + ".json"
+	if err := addTextToTar(tw, configPath, configFile); err != nil {
+		return err
+	}
+
+	layers, err := i.V1Image.Layers()
+	if err != nil {
+		return errors.Wrap(err, "get image layers")
+	}
+	written := map[string]bool{}
+	layerPaths := make([]string, 0, len(layers))
+	for _, layer := range layers {
+		digest, err := layer.Digest()
+		if err != nil {
+			return err
+		}
+		layerPath := digest.Hex + ".tar.gz"
+		layerPaths = append(layerPaths, layerPath)
+		if written[layerPath] {
+			continue
+		}
+		if err := addLayerToTar(tw, layerPath, layer); err != nil {
+			return errors.Wrapf(err, "write layer '%s'", digest)
+		}
+		written[layerPath] = true
+	}
+
+	manifest, err := json.Marshal(tarball.Manifest{{
+		Config:   configPath,
+		RepoTags: i.repoTags,
+		Layers:   layerPaths,
+	}})
+	if err != nil {
+		return err
+	}
+	if err := addTextToTar(tw, "manifest.json", manifest); err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+func addTextToTar(tw *tar.Writer, name string, contents []byte) error {
+	hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(contents))}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(contents)
+	return err
+}
+
+// addLayerToTar writes the compressed contents of the layer, whose size is known without reading them.
+func addLayerToTar(tw *tar.Writer, name string, layer v1.Layer) error {
+	size, err := layer.Size()
+	if err != nil {
+		return err
+	}
+	rc, err := layer.Compressed()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	hdr := &tar.Header{Name: name, Mode: 0644, Size: size}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, rc)
+	return err
+}
+
+func (i *Image) Delete() error {
+	if !isArchive(i.path) {
+		return nil
+	}
+	return os.Remove(i.path)
+}
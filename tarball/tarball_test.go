@@ -0,0 +1,368 @@
+package tarball_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	ggcrtarball "github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/imgutiltest"
+	"github.com/buildpacks/imgutil/tarball"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestTarball(t *testing.T) {
+	rand.Seed(time.Now().UTC().UnixNano())
+
+	spec.Run(t, "Image", testImage, spec.Parallel(), spec.Report(report.Terminal{}))
+
+	tmpDir, err := ioutil.TempDir("", "imgutil.tarball.conformance.")
+	h.AssertNil(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	imgutiltest.Run(t, imgutiltest.Factory{
+		NewName: func(t *testing.T) string {
+			return newImagePath(tmpDir)
+		},
+		NewImage: func(t *testing.T, path, basePath, prevPath string) imgutil.Image {
+			var ops []tarball.ImageOption
+			if basePath != "" {
+				ops = append(ops, tarball.FromBaseImage(basePath))
+			}
+			if prevPath != "" {
+				ops = append(ops, tarball.WithPreviousImage(prevPath))
+			}
+			img, err := tarball.NewImage(path, ops...)
+			h.AssertNil(t, err)
+			return img
+		},
+	})
+}
+
+func testImage(t *testing.T, when spec.G, it spec.S) {
+	var (
+		tmpDir    string
+		imagePath string
+	)
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "imgutil.tarball.test.")
+		h.AssertNil(t, err)
+
+		imagePath = newImagePath(tmpDir)
+	})
+
+	it.After(func() {
+		h.AssertNil(t, os.RemoveAll(tmpDir))
+	})
+
+	it("implements imgutil.Image", func() {
+		var _ imgutil.Image = &tarball.Image{}
+	})
+
+	when("#NewImage", func() {
+		when("no base image is given", func() {
+			it("sets sensible defaults for all required fields", func() {
+				img, err := tarball.NewImage(imagePath)
+				h.AssertNil(t, err)
+				h.AssertNil(t, img.Save())
+
+				os, err := img.OS()
+				h.AssertNil(t, err)
+				h.AssertEq(t, os, "linux")
+
+				arch, err := img.Architecture()
+				h.AssertNil(t, err)
+				h.AssertEq(t, arch, "amd64")
+			})
+		})
+
+		when("#FromBaseImage", func() {
+			when("base image exists", func() {
+				it("sets the initial state from the base image", func() {
+					basePath := newImagePath(tmpDir)
+					layerPath, err := h.CreateSingleFileLayerTar("/base.txt", "base", "linux")
+					h.AssertNil(t, err)
+					defer os.Remove(layerPath)
+
+					baseImage, err := tarball.NewImage(basePath)
+					h.AssertNil(t, err)
+					h.AssertNil(t, baseImage.SetLabel("some.label", "some.value"))
+					h.AssertNil(t, baseImage.AddLayer(layerPath))
+					h.AssertNil(t, baseImage.Save())
+
+					img, err := tarball.NewImage(imagePath, tarball.FromBaseImage(basePath))
+					h.AssertNil(t, err)
+
+					label, err := img.Label("some.label")
+					h.AssertNil(t, err)
+					h.AssertEq(t, label, "some.value")
+
+					topLayer, err := img.TopLayer()
+					h.AssertNil(t, err)
+					h.AssertEq(t, topLayer, h.FileDiffID(t, layerPath))
+				})
+			})
+
+			when("base image does not exist", func() {
+				it("doesn't error", func() {
+					_, err := tarball.NewImage(imagePath, tarball.FromBaseImage(filepath.Join(tmpDir, "some-bad-path")))
+					h.AssertNil(t, err)
+				})
+			})
+
+			when("base image is not an archive", func() {
+				it("returns an error", func() {
+					badPath := filepath.Join(tmpDir, "not-an-archive")
+					h.AssertNil(t, ioutil.WriteFile(badPath, []byte("some-contents"), 0644))
+
+					_, err := tarball.NewImage(imagePath, tarball.FromBaseImage(badPath))
+					h.AssertError(t, err, "read image archive at path")
+				})
+			})
+		})
+
+		when("#WithPreviousImage", func() {
+			when("previous image does not exist", func() {
+				it("doesn't error", func() {
+					_, err := tarball.NewImage(imagePath, tarball.WithPreviousImage(filepath.Join(tmpDir, "some-bad-path")))
+					h.AssertNil(t, err)
+				})
+			})
+		})
+
+		when("#WithRepoTags", func() {
+			it("returns an error for an invalid tag", func() {
+				_, err := tarball.NewImage(imagePath, tarball.WithRepoTags("Not A Tag"))
+				h.AssertError(t, err, "invalid repo tag 'Not A Tag'")
+			})
+		})
+	})
+
+	when("#Save", func() {
+		it("writes a docker save archive", func() {
+			img, err := tarball.NewImage(imagePath, tarball.WithRepoTags("some/image", "other/image:some-tag"))
+			h.AssertNil(t, err)
+
+			layerPath, err := h.CreateSingleFileLayerTar("/new-layer.txt", "new-layer", "linux")
+			h.AssertNil(t, err)
+			defer os.Remove(layerPath)
+
+			h.AssertNil(t, img.AddLayer(layerPath))
+			h.AssertNil(t, img.Save())
+
+			var manifest ggcrtarball.Manifest
+			h.AssertNil(t, json.Unmarshal(readArchiveFile(t, imagePath, "manifest.json"), &manifest))
+			h.AssertEq(t, len(manifest), 1)
+			h.AssertEq(t, manifest[0].RepoTags, []string{"index.docker.io/some/image:latest", "index.docker.io/other/image:some-tag"})
+
+			identifier, err := img.Identifier()
+			h.AssertNil(t, err)
+			h.AssertEq(t, manifest[0].Config, strings.TrimPrefix(identifier.String(), "sha256:")+".json")
+
+			expected, err := ioutil.ReadFile(layerPath)
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(manifest[0].Layers), 1)
+			compressed := readArchiveFile(t, imagePath, manifest[0].Layers[0])
+			h.AssertEq(t, manifest[0].Layers[0], fmt.Sprintf("%x.tar.gz", sha256.Sum256(compressed)))
+			gzr, err := gzip.NewReader(bytes.NewReader(compressed))
+			h.AssertNil(t, err)
+			uncompressed, err := ioutil.ReadAll(gzr)
+			h.AssertNil(t, err)
+			h.AssertEq(t, string(uncompressed), string(expected))
+		})
+
+		it("zeroes all times and client specific fields", func() {
+			img, err := tarball.NewImage(imagePath)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.Save())
+
+			createdAt, err := img.CreatedAt()
+			h.AssertNil(t, err)
+			h.AssertEq(t, createdAt, imgutil.NormalizedDateTime)
+		})
+
+		it("replaces the archive the image was read from", func() {
+			layerPath, err := h.CreateSingleFileLayerTar("/layer.txt", "layer", "linux")
+			h.AssertNil(t, err)
+			defer os.Remove(layerPath)
+
+			img, err := tarball.NewImage(imagePath)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.AddLayer(layerPath))
+			h.AssertNil(t, img.Save())
+
+			img, err = tarball.NewImage(imagePath, tarball.FromBaseImage(imagePath))
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.SetLabel("mykey", "new-val"))
+			h.AssertNil(t, img.Save())
+
+			saved, err := tarball.NewImage(imagePath, tarball.FromBaseImage(imagePath))
+			h.AssertNil(t, err)
+			label, err := saved.Label("mykey")
+			h.AssertNil(t, err)
+			h.AssertEq(t, label, "new-val")
+			topLayer, err := saved.TopLayer()
+			h.AssertNil(t, err)
+			h.AssertEq(t, topLayer, h.FileDiffID(t, layerPath))
+
+			entries, err := ioutil.ReadDir(tmpDir)
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(entries), 1)
+		})
+
+		when("the archive the image was read from has layers named like `docker save` does", func() {
+			var layerPath string
+
+			it.Before(func() {
+				var err error
+				layerPath, err = h.CreateSingleFileLayerTar("/layer.txt", "layer", "linux")
+				h.AssertNil(t, err)
+				writeDockerSaveArchive(t, imagePath, layerPath)
+			})
+
+			it.After(func() {
+				h.AssertNil(t, os.Remove(layerPath))
+			})
+
+			it("replaces the archive and keeps reading layers of the image", func() {
+				diffID := h.FileDiffID(t, layerPath)
+				expected, err := ioutil.ReadFile(layerPath)
+				h.AssertNil(t, err)
+
+				img, err := tarball.NewImage(imagePath, tarball.FromBaseImage(imagePath), tarball.WithPreviousImage(imagePath))
+				h.AssertNil(t, err)
+				otherPath := newImagePath(tmpDir)
+				h.AssertNil(t, img.Save(otherPath))
+
+				thirdPath := newImagePath(tmpDir)
+				img.Rename(thirdPath)
+				h.AssertNil(t, img.ReuseLayer(diffID))
+				h.AssertNil(t, img.Save())
+
+				rc, err := img.GetLayer(diffID)
+				h.AssertNil(t, err)
+				contents, err := ioutil.ReadAll(rc)
+				h.AssertNil(t, err)
+				h.AssertNil(t, rc.Close())
+				h.AssertEq(t, string(contents), string(expected))
+
+				for _, path := range []string{imagePath, otherPath, thirdPath} {
+					saved, err := tarball.NewImage(path, tarball.FromBaseImage(path))
+					h.AssertNil(t, err)
+					rc, err := saved.GetLayer(diffID)
+					h.AssertNil(t, err)
+					contents, err := ioutil.ReadAll(rc)
+					h.AssertNil(t, err)
+					h.AssertNil(t, rc.Close())
+					h.AssertEq(t, string(contents), string(expected))
+				}
+			})
+		})
+
+	})
+
+	when("#Found", func() {
+		it("returns false when nothing was saved", func() {
+			img, err := tarball.NewImage(imagePath)
+			h.AssertNil(t, err)
+			h.AssertEq(t, img.Found(), false)
+		})
+	})
+
+	when("#Delete", func() {
+		it("removes the archive", func() {
+			img, err := tarball.NewImage(imagePath)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.Save())
+			h.AssertEq(t, img.Found(), true)
+
+			h.AssertNil(t, img.Delete())
+			h.AssertEq(t, img.Found(), false)
+		})
+	})
+}
+
+func newImagePath(dir string) string {
+	return filepath.Join(dir, "tarball-image-test-"+h.RandString(10)+".tar")
+}
+
+func readArchiveFile(t *testing.T, path, name string) []byte {
+	t.Helper()
+
+	f, err := os.Open(path)
+	h.AssertNil(t, err)
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			t.Fatalf("archive '%s' has no file '%s'", path, name)
+		}
+		h.AssertNil(t, err)
+		if hdr.Name == name {
+			contents, err := ioutil.ReadAll(tr)
+			h.AssertNil(t, err)
+			return contents
+		}
+	}
+}
+
+// writeDockerSaveArchive writes an archive of an image with the single layer at layerPath, naming files the way
+// `docker save` does.
+func writeDockerSaveArchive(t *testing.T, path, layerPath string) {
+	t.Helper()
+
+	layer, err := ioutil.ReadFile(layerPath)
+	h.AssertNil(t, err)
+	diffID, err := v1.NewHash(h.FileDiffID(t, layerPath))
+	h.AssertNil(t, err)
+	config, err := json.Marshal(v1.ConfigFile{
+		OS:           "linux",
+		Architecture: "amd64",
+		RootFS:       v1.RootFS{Type: "layers", DiffIDs: []v1.Hash{diffID}},
+	})
+	h.AssertNil(t, err)
+	configPath := fmt.Sprintf("%x.json", sha256.Sum256(config))
+	manifest, err := json.Marshal(ggcrtarball.Manifest{{
+		Config: configPath,
+		Layers: []string{"abc/layer.tar"},
+	}})
+	h.AssertNil(t, err)
+
+	f, err := os.Create(path)
+	h.AssertNil(t, err)
+	defer f.Close()
+	tw := tar.NewWriter(f)
+	for _, file := range []struct {
+		name     string
+		contents []byte
+	}{
+		{configPath, config},
+		{"abc/layer.tar", layer},
+		{"manifest.json", manifest},
+	} {
+		h.AssertNil(t, tw.WriteHeader(&tar.Header{Name: file.name, Mode: 0644, Size: int64(len(file.contents))}))
+		_, err := tw.Write(file.contents)
+		h.AssertNil(t, err)
+	}
+	h.AssertNil(t, tw.Close())
+}
@@ -0,0 +1,569 @@
+package testhelpers
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestRegistry is a registry that tests push images to and pull images from.
+type TestRegistry interface {
+	Start(t *testing.T)
+	Stop(t *testing.T)
+	// Host returns the address images are named with, e.g. "localhost:5000".
+	Host() string
+}
+
+// NewTestRegistry returns an in-process registry, or a Docker registry container
+// when the IMGUTIL_TEST_REGISTRY environment variable is set to "docker".
+func NewTestRegistry() TestRegistry {
+	if os.Getenv("IMGUTIL_TEST_REGISTRY") == "docker" {
+		return NewDockerRegistry()
+	}
+	return NewInProcessRegistry()
+}
+
+func (registry *DockerRegistry) Host() string {
+	return "localhost:" + registry.Port
+}
+
+// InProcessRegistry is a registry implementing the parts of the distribution API used by imgutil,
+// served by the test process so that no Docker daemon is needed.
+type InProcessRegistry struct {
+	Port string
+
+	server   *httptest.Server
+	username string
+	password string
+	useToken bool
+	noMounts bool
+
+	useTLS            bool
+	requireClientCert bool
+	clientCert        tls.Certificate
+
+	lock      sync.Mutex
+	blobs     map[string][]byte
+	repoBlobs map[string]map[string]bool
+	uploads   map[string][]byte
+	manifests map[string]map[string]registryManifest
+	tokens    map[string]bool
+	uploadID  int
+	uploaded  map[string]int
+	mounted   map[string]int
+	checked   map[string]int
+	fetched   map[string]int
+}
+
+type registryManifest struct {
+	contentType string
+	blob        []byte
+}
+
+type RegistryOption func(*InProcessRegistry)
+
+// WithBasicAuth requires every request to the registry to use basic auth with the given credentials.
+func WithBasicAuth(username, password string) RegistryOption {
+	return func(r *InProcessRegistry) {
+		r.username = username
+		r.password = password
+		r.useToken = false
+	}
+}
+
+// WithTokenAuth requires every request to the registry to use a bearer token, which is handed out
+// by the registry's token endpoint in exchange for the given credentials.
+func WithTokenAuth(username, password string) RegistryOption {
+	return func(r *InProcessRegistry) {
+		r.username = username
+		r.password = password
+		r.useToken = true
+	}
+}
+
+// WithRefusedMounts makes the registry deny cross-repository blob mount requests instead of serving them.
+func WithRefusedMounts() RegistryOption {
+	return func(r *InProcessRegistry) {
+		r.noMounts = true
+	}
+}
+
+// WithTLS serves the registry over HTTPS with a certificate that isn't trusted by the system, see CACertificate.
+func WithTLS() RegistryOption {
+	return func(r *InProcessRegistry) {
+		r.useTLS = true
+	}
+}
+
+// WithClientCertificates serves the registry over HTTPS and requires clients to present a certificate,
+// see ClientCertificate.
+func WithClientCertificates() RegistryOption {
+	return func(r *InProcessRegistry) {
+		r.useTLS = true
+		r.requireClientCert = true
+	}
+}
+
+func NewInProcessRegistry(ops ...RegistryOption) *InProcessRegistry {
+	r := &InProcessRegistry{
+		blobs:     map[string][]byte{},
+		repoBlobs: map[string]map[string]bool{},
+		uploads:   map[string][]byte{},
+		manifests: map[string]map[string]registryManifest{},
+		tokens:    map[string]bool{},
+		uploaded:  map[string]int{},
+		mounted:   map[string]int{},
+		checked:   map[string]int{},
+		fetched:   map[string]int{},
+	}
+	for _, op := range ops {
+		op(r)
+	}
+	return r
+}
+
+func (r *InProcessRegistry) Start(t *testing.T) {
+	t.Helper()
+
+	r.server = httptest.NewUnstartedServer(http.HandlerFunc(r.handle))
+	if r.requireClientCert {
+		caCert, caKey := newCertificate(t, nil, nil, "imgutil test CA")
+		r.clientCert = tlsCertificate(newCertificate(t, caCert, caKey, "imgutil test client"))
+
+		pool := x509.NewCertPool()
+		pool.AddCert(caCert)
+		r.server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	}
+	if r.useTLS {
+		r.server.StartTLS()
+	} else {
+		r.server.Start()
+	}
+
+	u, err := url.Parse(r.server.URL)
+	AssertNil(t, err)
+	r.Port = u.Port()
+}
+
+func (r *InProcessRegistry) Stop(t *testing.T) {
+	t.Helper()
+
+	if r.server != nil {
+		r.server.Close()
+	}
+}
+
+func (r *InProcessRegistry) Host() string {
+	if r.useTLS {
+		// the certificate of the test server is only valid for IP addresses and example.com
+		return "127.0.0.1:" + r.Port
+	}
+	return "localhost:" + r.Port
+}
+
+// CACertificate returns the PEM encoded certificate that the registry serves HTTPS with.
+func (r *InProcessRegistry) CACertificate() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: r.server.Certificate().Raw})
+}
+
+// ClientCertificate returns a certificate that the registry accepts from clients.
+func (r *InProcessRegistry) ClientCertificate() tls.Certificate {
+	return r.clientCert
+}
+
+// newCertificate returns a certificate signed by parent, or a self-signed CA certificate when parent is nil.
+func newCertificate(t *testing.T, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, commonName string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	AssertNil(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	AssertNil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	AssertNil(t, err)
+	return cert, key
+}
+
+func tlsCertificate(cert *x509.Certificate, key *ecdsa.PrivateKey) tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{cert.Raw}, PrivateKey: key, Leaf: cert}
+}
+
+// BlobUploads returns the number of blobs whose contents were uploaded to repo.
+func (r *InProcessRegistry) BlobUploads(repo string) int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.uploaded[repo]
+}
+
+// BlobMounts returns the number of blobs mounted into repo from another repository.
+func (r *InProcessRegistry) BlobMounts(repo string) int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.mounted[repo]
+}
+
+// BlobChecks returns the number of HEAD requests made for blobs in repo.
+func (r *InProcessRegistry) BlobChecks(repo string) int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.checked[repo]
+}
+
+// ManifestFetches returns the number of GET requests made for manifests in repo.
+func (r *InProcessRegistry) ManifestFetches(repo string) int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.fetched[repo]
+}
+
+type registryError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *registryError) write(resp http.ResponseWriter) {
+	resp.Header().Set("Content-Type", "application/json")
+	resp.WriteHeader(e.status)
+	_ = json.NewEncoder(resp).Encode(map[string][]map[string]string{
+		"errors": {{"code": e.code, "message": e.message}},
+	})
+}
+
+func (r *InProcessRegistry) handle(resp http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/token" {
+		r.handleToken(resp, req)
+		return
+	}
+
+	if !r.authorized(req) {
+		if r.useToken {
+			resp.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="http://%s/token",service="imgutil-test-registry"`, req.Host))
+		} else {
+			resp.Header().Set("WWW-Authenticate", `Basic realm="imgutil-test-registry"`)
+		}
+		(&registryError{http.StatusUnauthorized, "UNAUTHORIZED", "authentication required"}).write(resp)
+		return
+	}
+
+	var rerr *registryError
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	switch {
+	case req.URL.Path == "/v2/" || req.URL.Path == "/v2":
+		resp.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+		resp.WriteHeader(http.StatusOK)
+	case strings.Contains(path, "/blobs/uploads"):
+		idx := strings.LastIndex(path, "/blobs/uploads")
+		rerr = r.handleUpload(resp, req, path[:idx], strings.Trim(path[idx+len("/blobs/uploads"):], "/"))
+	case strings.Contains(path, "/blobs/"):
+		idx := strings.LastIndex(path, "/blobs/")
+		rerr = r.handleBlob(resp, req, path[:idx], path[idx+len("/blobs/"):])
+	case strings.Contains(path, "/manifests/"):
+		idx := strings.LastIndex(path, "/manifests/")
+		rerr = r.handleManifest(resp, req, path[:idx], path[idx+len("/manifests/"):])
+	case strings.HasSuffix(path, "/tags/list"):
+		rerr = r.handleTags(resp, strings.TrimSuffix(path, "/tags/list"))
+	default:
+		rerr = &registryError{http.StatusNotFound, "NOT_FOUND", "unknown path"}
+	}
+	if rerr != nil {
+		rerr.write(resp)
+	}
+}
+
+func (r *InProcessRegistry) authorized(req *http.Request) bool {
+	if r.username == "" {
+		return true
+	}
+	if r.useToken {
+		token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		return r.tokens[token]
+	}
+	username, password, ok := req.BasicAuth()
+	return ok && username == r.username && password == r.password
+}
+
+func (r *InProcessRegistry) handleToken(resp http.ResponseWriter, req *http.Request) {
+	username, password, ok := req.BasicAuth()
+	if !r.useToken || !ok || username != r.username || password != r.password {
+		(&registryError{http.StatusUnauthorized, "UNAUTHORIZED", "invalid credentials"}).write(resp)
+		return
+	}
+
+	token := RandString(32)
+	r.lock.Lock()
+	r.tokens[token] = true
+	r.lock.Unlock()
+
+	resp.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(resp).Encode(map[string]string{"token": token})
+}
+
+func (r *InProcessRegistry) handleBlob(resp http.ResponseWriter, req *http.Request, repo, digest string) *registryError {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if req.Method == http.MethodHead {
+		r.checked[repo]++
+	}
+	if !r.repoBlobs[repo][digest] {
+		return &registryError{http.StatusNotFound, "BLOB_UNKNOWN", "unknown blob"}
+	}
+
+	switch req.Method {
+	case http.MethodHead, http.MethodGet:
+		blob := r.blobs[digest]
+		resp.Header().Set("Content-Length", fmt.Sprint(len(blob)))
+		resp.Header().Set("Docker-Content-Digest", digest)
+		resp.WriteHeader(http.StatusOK)
+		if req.Method == http.MethodGet {
+			_, _ = resp.Write(blob)
+		}
+		return nil
+	case http.MethodDelete:
+		delete(r.repoBlobs[repo], digest)
+		resp.WriteHeader(http.StatusAccepted)
+		return nil
+	}
+	return &registryError{http.StatusMethodNotAllowed, "UNSUPPORTED", "unsupported method"}
+}
+
+func (r *InProcessRegistry) handleUpload(resp http.ResponseWriter, req *http.Request, repo, id string) *registryError {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return &registryError{http.StatusBadRequest, "BLOB_UPLOAD_INVALID", err.Error()}
+	}
+	digest := req.URL.Query().Get("digest")
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	switch {
+	case req.Method == http.MethodPost && id == "":
+		mount, from := req.URL.Query().Get("mount"), req.URL.Query().Get("from")
+		if mount != "" && from != "" && r.noMounts {
+			return &registryError{http.StatusForbidden, "DENIED", "mounting blobs is not allowed"}
+		}
+		if mount != "" && r.repoBlobs[from][mount] {
+			r.linkBlob(repo, mount)
+			r.mounted[repo]++
+			resp.Header().Set("Location", "/v2/"+repo+"/blobs/"+mount)
+			resp.Header().Set("Docker-Content-Digest", mount)
+			resp.WriteHeader(http.StatusCreated)
+			return nil
+		}
+		if digest != "" {
+			return r.commitBlob(resp, repo, digest, body)
+		}
+		r.uploadID++
+		id = fmt.Sprint(r.uploadID)
+		r.uploads[id] = body
+		r.writeUploadStatus(resp, repo, id, http.StatusAccepted)
+		return nil
+	case req.Method == http.MethodPatch && id != "":
+		upload, ok := r.uploads[id]
+		if !ok {
+			return &registryError{http.StatusNotFound, "BLOB_UPLOAD_UNKNOWN", "unknown upload"}
+		}
+		r.uploads[id] = append(upload, body...)
+		r.writeUploadStatus(resp, repo, id, http.StatusAccepted)
+		return nil
+	case req.Method == http.MethodPut && id != "":
+		upload, ok := r.uploads[id]
+		if !ok {
+			return &registryError{http.StatusNotFound, "BLOB_UPLOAD_UNKNOWN", "unknown upload"}
+		}
+		delete(r.uploads, id)
+		return r.commitBlob(resp, repo, digest, append(upload, body...))
+	}
+	return &registryError{http.StatusMethodNotAllowed, "UNSUPPORTED", "unsupported method"}
+}
+
+func (r *InProcessRegistry) writeUploadStatus(resp http.ResponseWriter, repo, id string, status int) {
+	resp.Header().Set("Location", "/v2/"+repo+"/blobs/uploads/"+id)
+	resp.Header().Set("Docker-Upload-UUID", id)
+	end := len(r.uploads[id]) - 1
+	if end < 0 {
+		end = 0
+	}
+	resp.Header().Set("Range", fmt.Sprintf("0-%d", end))
+	resp.WriteHeader(status)
+}
+
+func (r *InProcessRegistry) commitBlob(resp http.ResponseWriter, repo, digest string, blob []byte) *registryError {
+	if digest != sha256Digest(blob) {
+		return &registryError{http.StatusBadRequest, "DIGEST_INVALID", "digest does not match contents"}
+	}
+	r.blobs[digest] = blob
+	r.linkBlob(repo, digest)
+	r.uploaded[repo]++
+	resp.Header().Set("Location", "/v2/"+repo+"/blobs/"+digest)
+	resp.Header().Set("Docker-Content-Digest", digest)
+	resp.WriteHeader(http.StatusCreated)
+	return nil
+}
+
+func (r *InProcessRegistry) linkBlob(repo, digest string) {
+	if r.repoBlobs[repo] == nil {
+		r.repoBlobs[repo] = map[string]bool{}
+	}
+	r.repoBlobs[repo][digest] = true
+}
+
+func (r *InProcessRegistry) handleManifest(resp http.ResponseWriter, req *http.Request, repo, ref string) *registryError {
+	var body []byte
+	if req.Method == http.MethodPut {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return &registryError{http.StatusBadRequest, "MANIFEST_INVALID", err.Error()}
+		}
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	switch req.Method {
+	case http.MethodHead, http.MethodGet:
+		if req.Method == http.MethodGet {
+			r.fetched[repo]++
+		}
+		m, ok := r.manifests[repo][ref]
+		if !ok {
+			return &registryError{http.StatusNotFound, "MANIFEST_UNKNOWN", "unknown manifest"}
+		}
+		resp.Header().Set("Docker-Content-Digest", sha256Digest(m.blob))
+		resp.Header().Set("Content-Type", m.contentType)
+		resp.Header().Set("Content-Length", fmt.Sprint(len(m.blob)))
+		resp.WriteHeader(http.StatusOK)
+		if req.Method == http.MethodGet {
+			_, _ = io.Copy(resp, bytes.NewReader(m.blob))
+		}
+		return nil
+	case http.MethodPut:
+		if rerr := r.verifyReferences(repo, body); rerr != nil {
+			return rerr
+		}
+		if r.manifests[repo] == nil {
+			r.manifests[repo] = map[string]registryManifest{}
+		}
+		digest := sha256Digest(body)
+		m := registryManifest{contentType: req.Header.Get("Content-Type"), blob: body}
+		r.manifests[repo][ref] = m
+		r.manifests[repo][digest] = m
+		resp.Header().Set("Location", "/v2/"+repo+"/manifests/"+digest)
+		resp.Header().Set("Docker-Content-Digest", digest)
+		resp.WriteHeader(http.StatusCreated)
+		return nil
+	case http.MethodDelete:
+		m, ok := r.manifests[repo][ref]
+		if !ok {
+			return &registryError{http.StatusNotFound, "MANIFEST_UNKNOWN", "unknown manifest"}
+		}
+		if !strings.HasPrefix(ref, "sha256:") {
+			delete(r.manifests[repo], ref)
+		} else {
+			// deleting by digest also removes every tag that points at the manifest
+			for tag, tagged := range r.manifests[repo] {
+				if bytes.Equal(tagged.blob, m.blob) {
+					delete(r.manifests[repo], tag)
+				}
+			}
+		}
+		resp.WriteHeader(http.StatusAccepted)
+		return nil
+	}
+	return &registryError{http.StatusMethodNotAllowed, "UNSUPPORTED", "unsupported method"}
+}
+
+// verifyReferences checks that the blobs and child manifests a manifest refers to exist in repo.
+func (r *InProcessRegistry) verifyReferences(repo string, body []byte) *registryError {
+	var m struct {
+		Config    *struct{ Digest string }  `json:"config"`
+		Layers    []struct{ Digest string } `json:"layers"`
+		Manifests []struct{ Digest string } `json:"manifests"`
+	}
+	if err := json.Unmarshal(body, &m); err != nil {
+		return &registryError{http.StatusBadRequest, "MANIFEST_INVALID", err.Error()}
+	}
+
+	blobs := m.Layers
+	if m.Config != nil {
+		blobs = append(blobs, *m.Config)
+	}
+	for _, blob := range blobs {
+		if !r.repoBlobs[repo][blob.Digest] {
+			return &registryError{http.StatusBadRequest, "MANIFEST_BLOB_UNKNOWN", "unknown blob " + blob.Digest}
+		}
+	}
+	for _, child := range m.Manifests {
+		if _, ok := r.manifests[repo][child.Digest]; !ok {
+			return &registryError{http.StatusBadRequest, "MANIFEST_UNKNOWN", "unknown manifest " + child.Digest}
+		}
+	}
+	return nil
+}
+
+func (r *InProcessRegistry) handleTags(resp http.ResponseWriter, repo string) *registryError {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	manifests, ok := r.manifests[repo]
+	if !ok {
+		return &registryError{http.StatusNotFound, "NAME_UNKNOWN", "unknown repository"}
+	}
+	tags := []string{}
+	for ref := range manifests {
+		if !strings.HasPrefix(ref, "sha256:") {
+			tags = append(tags, ref)
+		}
+	}
+	sort.Strings(tags)
+
+	resp.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(resp).Encode(map[string]interface{}{"name": repo, "tags": tags})
+	return nil
+}
+
+func sha256Digest(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}